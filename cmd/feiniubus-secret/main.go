@@ -0,0 +1,196 @@
+// Command feiniubus-secret manages encrypted values in the shared
+// ~/.feiniubus config files.
+//
+//	feiniubus-secret keygen
+//	feiniubus-secret encrypt VALUE
+//	feiniubus-secret decrypt enc:...
+//	feiniubus-secret rotate FILE...
+//
+// The key file defaults to secret.key in the shared config directory and can
+// be changed with -key or FEINIUBUS_KEY_FILE.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FeiniuBus/ecosystem/config"
+)
+
+func main() {
+	keyFile := flag.String("key", "", "path of the key file")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	if *keyFile == "" {
+		name, err := config.KeyFile()
+		if err != nil {
+			fatal(err)
+		}
+		*keyFile = name
+	}
+
+	var err error
+	args := flag.Args()
+	switch args[0] {
+	case "keygen":
+		err = keygen(*keyFile)
+	case "encrypt":
+		err = encrypt(*keyFile, args[1:])
+	case "decrypt":
+		err = decrypt(*keyFile, args[1:])
+	case "rotate":
+		err = rotate(*keyFile, args[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: feiniubus-secret [-key file] keygen | encrypt [value] | decrypt value | rotate file...\n")
+	flag.PrintDefaults()
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "feiniubus-secret: %s\n", err.Error())
+	os.Exit(1)
+}
+
+func keygen(keyFile string) error {
+	if _, err := os.Stat(keyFile); err == nil {
+		return fmt.Errorf("%s already exists, use rotate to replace it", keyFile)
+	}
+
+	key, err := config.GenerateKey()
+	if err != nil {
+		return err
+	}
+	return config.WriteKey(keyFile, key)
+}
+
+// encrypt reads the value from stdin when it is not given, so it does not
+// end up in the shell history.
+func encrypt(keyFile string, args []string) error {
+	key, err := config.ReadKey(keyFile)
+	if err != nil {
+		return err
+	}
+
+	var value string
+	if len(args) > 0 {
+		value = args[0]
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+
+	s, err := config.Encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(s)
+	return nil
+}
+
+func decrypt(keyFile string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("decrypt takes exactly one value")
+	}
+
+	key, err := config.ReadKey(keyFile)
+	if err != nil {
+		return err
+	}
+
+	s, err := config.Decrypt(key, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(s)
+	return nil
+}
+
+// rotate re-encrypts every file with a new key. All files are rewritten in
+// memory before anything touches the disk. The old key is saved next to the
+// new one with an .old suffix before the new key replaces it, and only then
+// are the files replaced one by one, so that every file can be decrypted
+// with one of the two keys if rotate stops half way.
+func rotate(keyFile string, files []string) error {
+	oldKey, err := config.ReadKey(keyFile)
+	if err != nil {
+		return err
+	}
+	newKey, err := config.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	contents := make([][]byte, len(files))
+	for i, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if contents[i], err = config.Reencrypt(data, oldKey, newKey); err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+	}
+
+	if err := config.WriteKey(keyFile+".old", oldKey); err != nil {
+		return err
+	}
+	if err := config.WriteKey(keyFile, newKey); err != nil {
+		return err
+	}
+	for i, name := range files {
+		if err := writeFile(name, contents[i]); err != nil {
+			return fmt.Errorf("%s: %s (the old key is in %s.old)", name, err.Error(), keyFile)
+		}
+	}
+	return nil
+}
+
+// writeFile replaces name with data through a temporary file, keeping its
+// mode
+func writeFile(name string, data []byte) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(info.Mode())
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Format is the encoding of a config file.
+type Format string
+
+// Supported formats
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// sharedExtensions lists the extensions probed, in order, for a shared
+// config file such as redis.json.
+var sharedExtensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// WithFormat makes the File decode its data source as format regardless of
+// the file extension.
+func WithFormat(format Format) Option {
+	return func(f *File) {
+		f.format = format
+	}
+}
+
+// formatOf returns the format implied by the extension of name, or an
+// empty Format when the extension is not recognized.
+func formatOf(name string) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return ""
+}
+
+// decodeTree decodes buf into generic maps, slices and scalars that
+// encoding/json can marshal, so every format binds through the same json
+// struct tags. offset is the byte offset of a syntax error, or -1.
+func decodeTree(format Format, buf []byte) (tree interface{}, offset int, err error) {
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(buf, &tree); err != nil {
+			return nil, -1, err
+		}
+		if tree, err = normalizeYAML(tree); err != nil {
+			return nil, -1, err
+		}
+		return tree, -1, nil
+	case FormatTOML:
+		var m map[string]interface{}
+		if _, err := toml.Decode(string(buf), &m); err != nil {
+			return nil, -1, err
+		}
+		return m, -1, nil
+	case FormatJSON, "":
+		return decodeJSONTree(buf)
+	}
+	return nil, -1, fmt.Errorf("unsupported format %q", format)
+}
+
+func decodeJSONTree(buf []byte) (tree interface{}, offset int, err error) {
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	if err := d.Decode(&tree); err != nil {
+		if e, ok := err.(*json.SyntaxError); ok {
+			return nil, int(e.Offset), err
+		}
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, len(buf), errors.New("unexpected end of JSON input")
+		}
+		return nil, -1, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, int(d.InputOffset()), errors.New("unexpected data after top-level value")
+	}
+	return tree, -1, nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced
+// by yaml.v2 into map[string]interface{}.
+func normalizeYAML(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			value, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = value
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range t {
+			value, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = value
+		}
+	}
+	return v, nil
+}
+
+// keyPattern matches an object key called name in a document of the given
+// format, with the key itself as the first group. Like encoding/json the
+// match is case-insensitive.
+func keyPattern(format Format, name string) string {
+	quoted := `(["']?` + regexp.QuoteMeta(name) + `["']?)`
+	switch format {
+	case FormatYAML:
+		return `(?im)^[ \t-]*` + quoted + `[ \t]*:`
+	case FormatTOML:
+		return `(?im)^[ \t]*\[?[ \t]*` + quoted + `[ \t]*(?:=|\])`
+	}
+	return `(?i)("` + regexp.QuoteMeta(name) + `")\s*:`
+}
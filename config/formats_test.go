@@ -0,0 +1,44 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSharedFileFormats(t *testing.T) {
+	cases := map[string]string{
+		"redis.yaml": "RedisConnectionStrings:\n  Endpoints:\n    - 10.0.0.1:6379\n  Password: secret\n  SyncTimeout: 2000\n",
+		"redis.toml": "[RedisConnectionStrings]\nEndpoints = [\"10.0.0.1:6379\"]\nPassword = \"secret\"\nSyncTimeout = 2000\n",
+	}
+
+	for filename, data := range cases {
+		dir, err := ioutil.TempDir("", "feiniubus")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		defer os.Setenv(ConfigDirEnvName, os.Getenv(ConfigDirEnvName))
+		os.Setenv(ConfigDirEnvName, dir)
+		ioutil.WriteFile(filepath.Join(dir, filename), []byte(data), 0600)
+
+		n, err := GetRedisNode()
+		if err != nil {
+			t.Fatalf("%s: %s", filename, err.Error())
+		}
+		if n.Password != "secret" || n.SyncTimeout != 2000 || n.ConnectTimeout != 5000 || len(n.Endpoints) != 1 {
+			t.Errorf("%s: unexpected node %+v", filename, n)
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	v := &redisConfig{}
+	data := "RedisConnectionStrings:\n  Endpoints: [a]\n  Pasword: x\n"
+	_, err := LoadSource(NewBytesSource([]byte(data)), &v, WithFormat(FormatYAML), Strict())
+	if err == nil || !strings.Contains(err.Error(), `<memory>:3:3: unknown field "Pasword"`) {
+		t.Errorf("got %v", err)
+	}
+}
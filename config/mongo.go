@@ -2,8 +2,13 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // consts
@@ -16,26 +21,67 @@ const (
 	DefaultMongoSocketTimeout         = 0
 
 	sharedMongoFilename = "mongo.json"
+	mongoScheme         = "mongodb://"
+	mongoSRVScheme      = "mongodb+srv://"
+)
+
+// MongoURIDialect selects how a MongoNode is written as a URI.
+type MongoURIDialect int
+
+// Mongo URI dialects
+const (
+	// MongoDialectLegacy writes the .NET driver style understood by our older
+	// services: options separated by ';' and timeouts as bare seconds.
+	MongoDialectLegacy MongoURIDialect = iota
+	// MongoDialectStandard follows the MongoDB connection string spec used by
+	// the official Go driver: options separated by '&' and *MS timeouts.
+	MongoDialectStandard
 )
 
 type mongoConfig struct {
-	MongoURL *MongoNode `json:"MongoUrl"`
+	MongoURL mongoProfiles `json:"MongoUrl"`
+
+	written profileSection
+}
+
+type mongoProfiles map[string]*MongoNode
+
+func (c *mongoConfig) normalizeTree(tree interface{}) interface{} {
+	tree, c.written = normalizeProfiles(tree, "MongoUrl", newMongoNode())
+	return tree
+}
+
+func (c *mongoConfig) writtenPath(path string) string {
+	return c.written.field(path)
 }
 
-// MongoNode is
+// MongoNode is a MongoDB deployment. Timeouts are in seconds.
+// MaxConnectionLifeTime has no standard equivalent and is only written by the
+// legacy dialect.
 type MongoNode struct {
 	Endpoints               []string
+	SRV                     bool
 	AuthenticationMechanism string
+	AuthSource              string
 	Username                string
 	Password                string
 	DatabaseName            string
 	ReplicaSet              string
+	TLS                     bool
+	TLSCAFile               string
 	ConnectTimeout          int
 	MaxConnectionIdleTime   int
 	MaxConnectionLifeTime   int
 	MaxConnectionPoolSize   int
 	MinConnectionPoolSize   int
 	SocketTimeout           int
+	ReadPreference          string
+	ReadPreferenceTags      []map[string]string
+	W                       string
+	Journal                 *bool
+	WTimeout                int
+	RetryWrites             *bool
+	AppName                 string
 }
 
 func newMongoNode() *MongoNode {
@@ -53,21 +99,89 @@ func newMongoNode() *MongoNode {
 	}
 }
 
-func (n *MongoNode) build() string {
+// Validate implements Validator
+func (c *mongoConfig) Validate() error {
+	if len(c.MongoURL) == 0 {
+		return errors.New("MongoUrl is required")
+	}
+	for _, name := range c.profiles() {
+		if err := c.MongoURL[name].Validate(); err != nil {
+			return fmt.Errorf("%s: %s", c.written.path(name), err.Error())
+		}
+	}
+	return nil
+}
+
+func (c *mongoConfig) profiles() []string {
+	names := make([]string, 0, len(c.MongoURL))
+	for name := range c.MongoURL {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks endpoints, pool sizes and timeouts
+func (n *MongoNode) Validate() error {
+	if len(n.Endpoints) == 0 {
+		return errors.New("Endpoints must not be empty")
+	}
+	if n.SRV && len(n.Endpoints) != 1 {
+		return errors.New("SRV requires exactly one endpoint")
+	}
+	for _, e := range n.Endpoints {
+		if err := validateEndpoint(e, false); err != nil {
+			return err
+		}
+		if n.SRV && strings.Contains(e, ":") {
+			return fmt.Errorf("SRV endpoint %q must not have a port", e)
+		}
+	}
+
+	if n.MaxConnectionPoolSize < 0 || n.MinConnectionPoolSize < 0 {
+		return errors.New("pool sizes must not be negative")
+	}
+	if n.MaxConnectionPoolSize > 0 && n.MinConnectionPoolSize > n.MaxConnectionPoolSize {
+		return fmt.Errorf("MinConnectionPoolSize %d exceeds MaxConnectionPoolSize %d", n.MinConnectionPoolSize, n.MaxConnectionPoolSize)
+	}
+	if n.ConnectTimeout < 0 || n.SocketTimeout < 0 || n.MaxConnectionIdleTime < 0 || n.MaxConnectionLifeTime < 0 || n.WTimeout < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	return nil
+}
+
+// String implements fmt.Stringer
+func (n *MongoNode) String() string {
+	return n.ConnectionString()
+}
+
+// ConnectionString returns the legacy dialect URI described by n, or an
+// empty string when n has no endpoints.
+func (n *MongoNode) ConnectionString() string {
+	return n.URI(MongoDialectLegacy)
+}
+
+// URI returns the URI described by n in the given dialect, or an empty
+// string when n has no endpoints.
+func (n *MongoNode) URI(dialect MongoURIDialect) string {
 	buffer := bytes.NewBufferString("")
 	if len(n.Endpoints) == 0 {
 		return buffer.String()
 	}
 
-	buffer.WriteString("mongodb://")
+	if n.SRV {
+		buffer.WriteString(mongoSRVScheme)
+	} else {
+		buffer.WriteString(mongoScheme)
+	}
 	if n.Username != "" {
-		buffer.WriteString(url.QueryEscape(n.Username))
+		buffer.WriteString(escapeUserinfo(n.Username))
 		if n.Password != "" {
-			buffer.WriteString(fmt.Sprintf(":%s", url.QueryEscape(n.Password)))
+			buffer.WriteString(fmt.Sprintf(":%s", escapeUserinfo(n.Password)))
 		}
 		buffer.WriteString("@")
 	} else if n.Password != "" {
-		buffer.WriteString(fmt.Sprintf(":%s@", n.Password))
+		buffer.WriteString(fmt.Sprintf(":%s@", escapeUserinfo(n.Password)))
 	}
 
 	firstServer := true
@@ -84,56 +198,364 @@ func (n *MongoNode) build() string {
 		buffer.WriteString(n.DatabaseName)
 	}
 
+	sep := ";"
+	if dialect == MongoDialectStandard {
+		sep = "&"
+	}
 	query := bytes.NewBufferString("")
+	for _, option := range n.options(dialect) {
+		if dialect == MongoDialectStandard {
+			option[1] = url.QueryEscape(option[1])
+		}
+		query.WriteString(fmt.Sprintf("%s=%s%s", option[0], option[1], sep))
+	}
+
+	if query.Len() != 0 {
+		query.Truncate(query.Len() - 1)
+		if n.DatabaseName == "" {
+			buffer.WriteString("/")
+		}
+		buffer.WriteString("?")
+		buffer.WriteString(query.String())
+	}
+
+	return buffer.String()
+}
+
+// escapeUserinfo percent-encodes a username or password. Unlike query
+// escaping a space becomes %20, so that a literal + survives, and the
+// delimiters : @ and / are escaped too.
+func escapeUserinfo(s string) string {
+	s = url.PathEscape(s)
+	s = strings.Replace(s, ":", "%3A", -1)
+	return strings.Replace(s, "@", "%40", -1)
+}
+
+// options returns the query options of n in the given dialect, in the
+// order they are written.
+func (n *MongoNode) options(dialect MongoURIDialect) [][2]string {
+	var options [][2]string
+	add := func(key, value string) {
+		options = append(options, [2]string{key, value})
+	}
+
 	if n.ReplicaSet != "" {
-		query.WriteString(fmt.Sprintf("replicaSet=%s;", n.ReplicaSet))
+		add("replicaSet", n.ReplicaSet)
 	}
 	if n.AuthenticationMechanism != "" {
-		query.WriteString(fmt.Sprintf("authMechanism=%s;", n.AuthenticationMechanism))
+		add("authMechanism", n.AuthenticationMechanism)
+	}
+
+	if dialect == MongoDialectStandard {
+		if n.AuthSource != "" {
+			add("authSource", n.AuthSource)
+		}
+		if n.TLS {
+			add("tls", "true")
+		}
+		if n.TLSCAFile != "" {
+			add("tlsCAFile", n.TLSCAFile)
+		}
+		if n.ConnectTimeout != DefaultMongoConnectTimeout {
+			add("connectTimeoutMS", strconv.Itoa(n.ConnectTimeout*1000))
+		}
+		if n.MaxConnectionIdleTime > 0 {
+			add("maxIdleTimeMS", strconv.Itoa(n.MaxConnectionIdleTime*1000))
+		}
+		if n.MaxConnectionPoolSize != DefaultMongoMaxConnectionPoolSize {
+			add("maxPoolSize", strconv.Itoa(n.MaxConnectionPoolSize))
+		}
+		if n.MinConnectionPoolSize != DefaultMongoMinConnectionPoolSize {
+			add("minPoolSize", strconv.Itoa(n.MinConnectionPoolSize))
+		}
+		if n.SocketTimeout > 0 {
+			add("socketTimeoutMS", strconv.Itoa(n.SocketTimeout*1000))
+		}
+	} else {
+		if n.ConnectTimeout != DefaultMongoConnectTimeout {
+			add("connectTimeout", strconv.Itoa(n.ConnectTimeout))
+		}
+		if n.MaxConnectionIdleTime != DefaultMongoMaxConnectionIdleTime {
+			add("maxIdleTime", strconv.Itoa(n.MaxConnectionIdleTime))
+		}
+		if n.MaxConnectionLifeTime != DefaultMongoMaxConnectionLifeTime {
+			add("maxLifeTime", strconv.Itoa(n.MaxConnectionLifeTime))
+		}
+		if n.MaxConnectionPoolSize != DefaultMongoMaxConnectionPoolSize {
+			add("maxPoolSize", strconv.Itoa(n.MaxConnectionPoolSize))
+		}
+		if n.MinConnectionPoolSize != DefaultMongoMinConnectionPoolSize {
+			add("minPoolSize", strconv.Itoa(n.MinConnectionPoolSize))
+		}
+		if n.SocketTimeout != DefaultMongoSocketTimeout {
+			add("socketTimeout", strconv.Itoa(n.SocketTimeout))
+		}
+		if n.AuthSource != "" {
+			add("authSource", n.AuthSource)
+		}
+		if n.TLS {
+			add("ssl", "true")
+		}
+	}
+
+	if n.ReadPreference != "" {
+		add("readPreference", n.ReadPreference)
 	}
-	if n.ConnectTimeout != DefaultMongoConnectTimeout {
-		query.WriteString(fmt.Sprintf("connectTimeout=%d;", n.ConnectTimeout))
+	for _, tags := range n.ReadPreferenceTags {
+		add("readPreferenceTags", formatTagSet(tags))
 	}
-	if n.MaxConnectionIdleTime != DefaultMongoMaxConnectionIdleTime {
-		query.WriteString(fmt.Sprintf("maxIdleTime=%d;", n.MaxConnectionIdleTime))
+	if n.W != "" {
+		add("w", n.W)
 	}
-	if n.MaxConnectionLifeTime != DefaultMongoMaxConnectionLifeTime {
-		query.WriteString(fmt.Sprintf("maxLifeTime=%d;", n.MaxConnectionLifeTime))
+	if n.Journal != nil {
+		add("journal", strconv.FormatBool(*n.Journal))
 	}
-	if n.MaxConnectionPoolSize != DefaultMongoMaxConnectionPoolSize {
-		query.WriteString(fmt.Sprintf("maxPoolSize=%d;", n.MaxConnectionPoolSize))
+	if n.WTimeout > 0 {
+		add("wtimeoutMS", strconv.Itoa(n.WTimeout))
 	}
-	if n.MinConnectionPoolSize != DefaultMongoMinConnectionPoolSize {
-		query.WriteString(fmt.Sprintf("minPoolSize=%d;", n.MinConnectionPoolSize))
+	if n.RetryWrites != nil {
+		add("retryWrites", strconv.FormatBool(*n.RetryWrites))
 	}
-	if n.SocketTimeout != DefaultMongoSocketTimeout {
-		query.WriteString(fmt.Sprintf("socketTimeout=%d;", n.SocketTimeout))
+	if n.AppName != "" {
+		add("appName", n.AppName)
 	}
+	return options
+}
 
-	if query.Len() != 0 {
-		query.Truncate(query.Len() - 1)
-		if n.DatabaseName == "" {
-			buffer.WriteString("/")
-		}
-		buffer.WriteString("?")
-		buffer.WriteString(query.String())
+func formatTagSet(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	return buffer.String()
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + ":" + tags[k]
+	}
+	return strings.Join(pairs, ",")
 }
 
-// GetMongoDialInfo returns mongodb url
-func GetMongoDialInfo(database string) (*MongoNode, error) {
+func parseTagSet(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	if s == "" {
+		return tags, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("mongo uri: invalid readPreferenceTags %q", s)
+		}
+		tags[pair[:i]] = pair[i+1:]
+	}
+	return tags, nil
+}
+
+// ParseMongoURI parses a mongodb:// or mongodb+srv:// URI in either dialect.
+// Options may be separated by ';' or '&', and option names are
+// case-insensitive. Millisecond timeouts are truncated to whole seconds, and
+// options that MongoNode has no field for, such as compressors, are ignored.
+func ParseMongoURI(uri string) (*MongoNode, error) {
 	n := newMongoNode()
-	c := &mongoConfig{
-		MongoURL: n,
+	var rest string
+	switch {
+	case strings.HasPrefix(uri, mongoScheme):
+		rest = uri[len(mongoScheme):]
+	case strings.HasPrefix(uri, mongoSRVScheme):
+		rest = uri[len(mongoSRVScheme):]
+		n.SRV = true
+	default:
+		return nil, fmt.Errorf("mongo uri must start with %s or %s", mongoScheme, mongoSRVScheme)
+	}
+
+	var query string
+	if i := strings.Index(rest, "?"); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rest, n.DatabaseName = rest[:i], rest[i+1:]
+	}
+
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		userinfo := rest[:i]
+		rest = rest[i+1:]
+
+		username, password := userinfo, ""
+		if j := strings.Index(userinfo, ":"); j >= 0 {
+			username, password = userinfo[:j], userinfo[j+1:]
+		}
+		var err error
+		if n.Username, err = url.PathUnescape(username); err != nil {
+			return nil, fmt.Errorf("mongo uri: invalid username: %s", err.Error())
+		}
+		if n.Password, err = url.PathUnescape(password); err != nil {
+			return nil, fmt.Errorf("mongo uri: invalid password: %s", err.Error())
+		}
+	}
+
+	for _, host := range strings.Split(rest, ",") {
+		if host == "" {
+			return nil, fmt.Errorf("mongo uri: empty host in %q", rest)
+		}
+		n.Endpoints = append(n.Endpoints, host)
+	}
+
+	for _, option := range strings.FieldsFunc(query, func(r rune) bool { return r == ';' || r == '&' }) {
+		if err := n.setOption(option); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (n *MongoNode) setOption(option string) error {
+	i := strings.Index(option, "=")
+	if i < 0 {
+		return fmt.Errorf("mongo uri: option %q has no value", option)
+	}
+	if i == 0 {
+		return fmt.Errorf("mongo uri: option %q has no name", option)
+	}
+	key := option[:i]
+	value, err := url.QueryUnescape(option[i+1:])
+	if err != nil {
+		return fmt.Errorf("mongo uri: option %s: %s", key, err.Error())
+	}
+
+	var target *int
+	scale := 1
+	switch strings.ToLower(key) {
+	case "replicaset":
+		n.ReplicaSet = value
+		return nil
+	case "authmechanism":
+		n.AuthenticationMechanism = value
+		return nil
+	case "authsource":
+		n.AuthSource = value
+		return nil
+	case "tls", "ssl":
+		n.TLS, err = strconv.ParseBool(value)
+	case "tlscafile":
+		n.TLSCAFile = value
+		return nil
+	case "readpreference":
+		n.ReadPreference = value
+		return nil
+	case "readpreferencetags":
+		var tags map[string]string
+		if tags, err = parseTagSet(value); err != nil {
+			return err
+		}
+		n.ReadPreferenceTags = append(n.ReadPreferenceTags, tags)
+		return nil
+	case "w":
+		n.W = value
+		return nil
+	case "journal":
+		var journal bool
+		journal, err = strconv.ParseBool(value)
+		n.Journal = &journal
+	case "retrywrites":
+		var retryWrites bool
+		retryWrites, err = strconv.ParseBool(value)
+		n.RetryWrites = &retryWrites
+	case "appname":
+		n.AppName = value
+		return nil
+	case "wtimeoutms":
+		target = &n.WTimeout
+	case "connecttimeout":
+		target = &n.ConnectTimeout
+	case "connecttimeoutms":
+		target, scale = &n.ConnectTimeout, 1000
+	case "maxidletime":
+		target = &n.MaxConnectionIdleTime
+	case "maxidletimems":
+		target, scale = &n.MaxConnectionIdleTime, 1000
+	case "maxlifetime":
+		target = &n.MaxConnectionLifeTime
+	case "maxpoolsize":
+		target = &n.MaxConnectionPoolSize
+	case "minpoolsize":
+		target = &n.MinConnectionPoolSize
+	case "sockettimeout":
+		target = &n.SocketTimeout
+	case "sockettimeoutms":
+		target, scale = &n.SocketTimeout, 1000
+	}
+
+	if target != nil {
+		var v int
+		if v, err = strconv.Atoi(value); err == nil {
+			*target = v / scale
+		}
 	}
+	if err != nil {
+		return fmt.Errorf("mongo uri: option %s: %s", key, err.Error())
+	}
+	return nil
+}
 
+func loadMongoConfig() (*mongoConfig, error) {
+	c := &mongoConfig{}
 	_, err := Load(sharedMongoFilename, &c)
 	if err != nil {
 		return nil, err
 	}
+	return c, nil
+}
+
+// GetMongoDialInfo returns the default mongodb node for database
+func GetMongoDialInfo(database string) (*MongoNode, error) {
+	return GetMongoProfile(defaultProfile, database)
+}
+
+// GetMongoProfile returns the named mongodb node for database, "default" if
+// profile is empty
+func GetMongoProfile(profile, database string) (*MongoNode, error) {
+	c, err := loadMongoConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		profile = defaultProfile
+	}
+
+	n, ok := c.MongoURL[profile]
+	if !ok {
+		return nil, fmt.Errorf("mongo profile %q: not found in MongoUrl", profile)
+	}
+
+	n.DatabaseName = database
+	return n, nil
+}
+
+// GetMongoProfiles returns the sorted profile names in mongo.json
+func GetMongoProfiles() ([]string, error) {
+	c, err := loadMongoConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.profiles(), nil
+}
 
-	c.MongoURL.DatabaseName = database
-	return c.MongoURL, nil
+// WatchMongo loads mongo.json, checks it for changes every interval and
+// calls fn with the profiles before and after each change, like
+// WatchRedis. The nodes have no DatabaseName.
+func WatchMongo(interval time.Duration, fn func(old, new map[string]*MongoNode)) (map[string]*MongoNode, *File, error) {
+	c := &mongoConfig{}
+	f, err := Load(sharedMongoFilename, &c)
+	if err != nil {
+		return nil, nil, err
+	}
+	f.OnChange(func(old, new interface{}) {
+		fn((*old.(**mongoConfig)).MongoURL, (*new.(**mongoConfig)).MongoURL)
+	})
+	if err := f.Watch(interval); err != nil {
+		return nil, nil, err
+	}
+	return c.MongoURL, f, nil
 }
@@ -1,36 +1,231 @@
 package config
 
 import (
-	"errors"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/url"
+	"sort"
+	"strconv"
 	"time"
 )
 
+// consts
 const (
+	DefaultMysqlPort     = 3306
+	DefaultMysqlCharset  = "utf8"
+	DefaultMysqlLocation = "Local"
+
 	sharedMysqlFilename = "mysql.json"
 	mysqlSectionName    = "MysqlConnectionStrings"
-	defaultMysqlProfile = "default"
+	defaultMysqlProfile = defaultProfile
 )
 
+type mysqlConfig struct {
+	MysqlConnectionStrings map[string]*MysqlNode
+}
+
+// MysqlNode is a single profile in mysql.json. Timeouts are in milliseconds.
+type MysqlNode struct {
+	Name              string `json:"-"`
+	Host              string
+	Port              int
+	User              string
+	Password          string
+	Charset           string
+	Collation         string
+	TLS               string
+	Timeout           int
+	ReadTimeout       int
+	WriteTimeout      int
+	InterpolateParams bool
+	MaxAllowedPacket  int
+	Location          string
+}
+
+func newMysqlNode() *MysqlNode {
+	return &MysqlNode{
+		Port:     DefaultMysqlPort,
+		Charset:  DefaultMysqlCharset,
+		Location: DefaultMysqlLocation,
+	}
+}
+
+type mysqlNode MysqlNode
+
+// UnmarshalJSON fills in defaults for the fields a profile leaves out
+func (n *MysqlNode) UnmarshalJSON(b []byte) error {
+	*n = *newMysqlNode()
+	return json.Unmarshal(b, (*mysqlNode)(n))
+}
+
+// Validate reports the first missing or invalid field of the profile
+func (n *MysqlNode) Validate() error {
+	switch {
+	case n.Host == "":
+		return n.errorf("Host is required")
+	case n.Port <= 0 || n.Port > 65535:
+		return n.errorf("Port %d is out of range", n.Port)
+	case n.User == "":
+		return n.errorf("User is required")
+	case n.Charset == "":
+		return n.errorf("Charset is required")
+	case n.Timeout < 0 || n.ReadTimeout < 0 || n.WriteTimeout < 0:
+		return n.errorf("timeouts must not be negative")
+	case n.MaxAllowedPacket < 0:
+		return n.errorf("MaxAllowedPacket must not be negative")
+	}
+
+	if _, err := n.location(); err != nil {
+		return n.errorf("Location: %s", err.Error())
+	}
+	return nil
+}
+
+// DSN returns a go-sql-driver/mysql data source name for database
+func (n *MysqlNode) DSN(database string) (string, error) {
+	if err := n.Validate(); err != nil {
+		return "", err
+	}
+
+	loc, _ := n.location()
+	params := url.Values{}
+	params.Set("charset", n.Charset)
+	params.Set("parseTime", "true")
+	params.Set("loc", loc.String())
+	if n.Collation != "" {
+		params.Set("collation", n.Collation)
+	}
+	if n.TLS != "" {
+		params.Set("tls", n.TLS)
+	}
+	if n.Timeout > 0 {
+		params.Set("timeout", milliseconds(n.Timeout))
+	}
+	if n.ReadTimeout > 0 {
+		params.Set("readTimeout", milliseconds(n.ReadTimeout))
+	}
+	if n.WriteTimeout > 0 {
+		params.Set("writeTimeout", milliseconds(n.WriteTimeout))
+	}
+	if n.InterpolateParams {
+		params.Set("interpolateParams", "true")
+	}
+	if n.MaxAllowedPacket > 0 {
+		params.Set("maxAllowedPacket", strconv.Itoa(n.MaxAllowedPacket))
+	}
+
+	buffer := bytes.NewBufferString("")
+	buffer.WriteString(n.User)
+	if n.Password != "" {
+		buffer.WriteString(":")
+		buffer.WriteString(n.Password)
+	}
+	buffer.WriteString("@tcp(")
+	buffer.WriteString(net.JoinHostPort(n.Host, strconv.Itoa(n.Port)))
+	buffer.WriteString(")/")
+	buffer.WriteString(database)
+	buffer.WriteString("?")
+	buffer.WriteString(params.Encode())
+	return buffer.String(), nil
+}
+
+func (n *MysqlNode) location() (*time.Location, error) {
+	if n.Location == "" || n.Location == DefaultMysqlLocation {
+		return time.Local, nil
+	}
+	return time.LoadLocation(n.Location)
+}
+
+func (n *MysqlNode) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("mysql profile %q: %s", n.Name, fmt.Sprintf(format, a...))
+}
+
+func milliseconds(ms int) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+func loadMysqlConfig() (*mysqlConfig, error) {
+	c := &mysqlConfig{}
+	_, err := Load(sharedMysqlFilename, &c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate implements Validator. It rejects empty profiles and names the
+// others; the profiles themselves are validated when they are used.
+func (c *mysqlConfig) Validate() error {
+	for name, n := range c.MysqlConnectionStrings {
+		if n == nil {
+			return fmt.Errorf("mysql profile %q: profile is empty", name)
+		}
+		n.Name = name
+	}
+	return nil
+}
+
+// GetMysqlProfiles returns the sorted profile names in mysql.json
+func GetMysqlProfiles() ([]string, error) {
+	c, err := loadMysqlConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]string, 0, len(c.MysqlConnectionStrings))
+	for name := range c.MysqlConnectionStrings {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// GetMysqlNode returns the validated mysql profile, "default" if profile is empty
+func GetMysqlNode(profile string) (*MysqlNode, error) {
+	c, err := loadMysqlConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		profile = defaultMysqlProfile
+	}
+
+	n, ok := c.MysqlConnectionStrings[profile]
+	if !ok {
+		return nil, fmt.Errorf("mysql profile %q: not found in %s", profile, mysqlSectionName)
+	}
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
 // GetMysqlConnectionString get mysql connection string
 func GetMysqlConnectionString(database, profile string) (string, error) {
-	var c map[string]interface{}
-	_, err := Load(sharedMysqlFilename, &c)
+	n, err := GetMysqlNode(profile)
 	if err != nil {
 		return "", err
 	}
+	return n.DSN(database)
+}
 
-	profileName := profile
-	if profileName == "" {
-		profileName = defaultMysqlProfile
+// WatchMysql loads mysql.json, checks it for changes every interval and
+// calls fn with the profiles before and after each change, like
+// WatchRedis. The profiles are validated by GetMysqlNode, not here.
+func WatchMysql(interval time.Duration, fn func(old, new map[string]*MysqlNode)) (map[string]*MysqlNode, *File, error) {
+	c := &mysqlConfig{}
+	f, err := Load(sharedMysqlFilename, &c)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	if m1, ok := c[mysqlSectionName].(map[string]interface{}); ok {
-		if m2, o := m1[profileName].(map[string]interface{}); o {
-			dsn := fmt.Sprintf("%s:%s@tcp(%s:%v)/%s?charset=%s&parseTime=true&loc=%s", m2["User"], m2["Password"], m2["Host"], m2["Port"], database, m2["Charset"], url.QueryEscape(time.Local.String()))
-			return dsn, nil
-		}
+	f.OnChange(func(old, new interface{}) {
+		fn((*old.(**mysqlConfig)).MysqlConnectionStrings, (*new.(**mysqlConfig)).MysqlConnectionStrings)
+	})
+	if err := f.Watch(interval); err != nil {
+		return nil, nil, err
 	}
-	return "", errors.New("读取mysql配置失败")
+	return c.MysqlConnectionStrings, f, nil
 }
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMysql(t *testing.T) {
+	RegisterSource(sharedMysqlFilename, NewBytesSource([]byte(`{
+		"MysqlConnectionStrings": {
+			"default": {"Host": "10.0.0.1", "User": "app", "Password": "p@ss", "Timeout": 5000, "Location": "Asia/Shanghai"},
+			"report": {"Host": "10.0.0.2", "Port": 3307}
+		}
+	}`)))
+	defer RegisterSource(sharedMysqlFilename, nil)
+
+	profiles, err := GetMysqlProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(profiles, ",") != "default,report" {
+		t.Errorf("profiles = %v", profiles)
+	}
+
+	dsn, err := GetMysqlConnectionString("orders", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app:p@ss@tcp(10.0.0.1:3306)/orders?charset=utf8&loc=Asia%2FShanghai&parseTime=true&timeout=5s"
+	if dsn != want {
+		t.Errorf("dsn = %s, want %s", dsn, want)
+	}
+
+	_, err = GetMysqlConnectionString("orders", "report")
+	if err == nil || !strings.Contains(err.Error(), `"report"`) || !strings.Contains(err.Error(), "User") {
+		t.Errorf("expected error naming profile and field, got %v", err)
+	}
+}
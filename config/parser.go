@@ -2,11 +2,45 @@ package config
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// Validator is implemented by config values that check themselves once
+// they are parsed.
+type Validator interface {
+	Validate() error
+}
+
+// ParseError is returned when a config file cannot be parsed or validated.
+// Line and Column are 1-based and zero when the position is unknown.
+type ParseError struct {
+	Path   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "config"
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", path, e.Line, e.Column, e.Err.Error())
+	}
+	return fmt.Sprintf("%s: %s", path, e.Err.Error())
+}
+
 type parser struct {
 	buf   *bufio.Reader
 	isEOF bool
@@ -22,13 +56,242 @@ func (p *parser) ReadAll() ([]byte, error) {
 	return ioutil.ReadAll(p.buf)
 }
 
-func (f *File) parse(reader io.Reader) (err error) {
-	p := newParser(reader)
-	buf, err := p.ReadAll()
+func (f *File) parse(name string, format Format, buf []byte, tree interface{}, v interface{}) (err error) {
+	fail := func(offset int, err error) error {
+		e := &ParseError{Path: name, Err: err}
+		if offset >= 0 {
+			e.Line, e.Column = position(buf, offset)
+		}
+		return e
+	}
+
+	if tree == nil {
+		var offset int
+		if tree, offset, err = decodeTree(format, buf); err != nil {
+			return fail(offset, err)
+		}
+	} else {
+		// positions in pre-decoded content mean nothing
+		buf = nil
+	}
+
+	r := &secretResolver{}
+	if tree, err = r.resolve(tree); err != nil {
+		return fail(-1, err)
+	}
+	tree = normalize(v, tree)
+	resolved, err := json.Marshal(tree)
 	if err != nil {
-		return err
+		return fail(-1, err)
+	}
+
+	if f.strict {
+		if field := unknownField(reflect.TypeOf(v), tree); field != "" {
+			return fail(keyOffset(format, buf, field), fmt.Errorf("unknown field %q", field))
+		}
+	}
+
+	d := json.NewDecoder(bytes.NewReader(resolved))
+	if f.strict {
+		d.DisallowUnknownFields()
+	}
+	if err := d.Decode(v); err != nil {
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			path := writtenPath(v, e.Field)
+			field := path[strings.LastIndex(path, ".")+1:]
+			return fail(keyOffset(format, buf, field), fmt.Errorf("%s: cannot use %s as %s", path, e.Value, e.Type.String()))
+		}
+		return fail(-1, err)
+	}
+
+	if err := validate(v); err != nil {
+		return fail(-1, err)
+	}
+	return nil
+}
+
+// validate calls Validate on the first value along the pointer chain of v
+// that implements Validator.
+func validate(v interface{}) error {
+	if validator, ok := firstImplementing(v, (*Validator)(nil)).(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// firstImplementing follows the pointer chain of v and returns the first
+// non-nil value implementing the interface iface points to, or nil.
+func firstImplementing(v interface{}, iface interface{}) interface{} {
+	t := reflect.TypeOf(iface).Elem()
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Implements(t) {
+			return rv.Interface()
+		}
+		if rv.Kind() != reflect.Ptr {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	return nil
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownField returns the first key of tree, in sorted order and depth
+// first, that has no field to bind to when tree is decoded into a value of
+// type t, or "".
+func unknownField(t reflect.Type, tree interface{}) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return ""
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := tree.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		fields := jsonFields(t, nil)
+		for _, key := range sortedKeys(m) {
+			ft, ok := fields[key]
+			if !ok {
+				for name, typ := range fields {
+					if strings.EqualFold(name, key) {
+						ft, ok = typ, true
+						break
+					}
+				}
+			}
+			if !ok {
+				return key
+			}
+			if field := unknownField(ft, m[key]); field != "" {
+				return field
+			}
+		}
+	case reflect.Map:
+		if m, ok := tree.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(m) {
+				if field := unknownField(t.Elem(), m[key]); field != "" {
+					return field
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := tree.([]interface{}); ok {
+			for _, item := range items {
+				if field := unknownField(t.Elem(), item); field != "" {
+					return field
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// jsonFields adds the fields encoding/json binds in struct type t, by name,
+// to fields, including those promoted from embedded structs.
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) map[string]reflect.Type {
+	if fields == nil {
+		fields = make(map[string]reflect.Type)
 	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if j := strings.Index(tag, ","); j >= 0 {
+			name = tag[:j]
+		}
 
-	err = json.Unmarshal(buf, &f.v)
-	return err
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				jsonFields(ft, fields)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := fields[name]; !ok {
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyOffset returns the offset of the first object key matching name in
+// buf, or -1.
+func keyOffset(format Format, buf []byte, name string) int {
+	if name == "" {
+		return -1
+	}
+	loc := regexp.MustCompile(keyPattern(format, name)).FindSubmatchIndex(buf)
+	if loc == nil {
+		return -1
+	}
+	return loc[2]
+}
+
+// position converts a byte offset in buf to a 1-based line and column
+func position(buf []byte, offset int) (line, column int) {
+	if offset > len(buf) {
+		offset = len(buf)
+	}
+	line = 1 + bytes.Count(buf[:offset], []byte("\n"))
+	column = offset - bytes.LastIndex(buf[:offset], []byte("\n"))
+	return line, column
+}
+
+// validateEndpoint checks that e is a host or host:port. IPv6 addresses
+// must be bracketed, e.g. [::1]:27017.
+func validateEndpoint(e string, requirePort bool) error {
+	host, port := e, ""
+	if strings.HasPrefix(e, "[") || strings.Count(e, ":") == 1 {
+		var err error
+		if host, port, err = net.SplitHostPort(e); err != nil {
+			return fmt.Errorf("invalid endpoint %q: %s", e, err.Error())
+		}
+	} else if strings.Contains(e, ":") {
+		return fmt.Errorf("invalid endpoint %q: IPv6 addresses must be in brackets", e)
+	}
+
+	if host == "" || strings.ContainsAny(host, " ,/?@") {
+		return fmt.Errorf("invalid endpoint %q: bad host", e)
+	}
+	if port == "" {
+		if requirePort {
+			return fmt.Errorf("invalid endpoint %q: missing port", e)
+		}
+		return nil
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("invalid endpoint %q: bad port", e)
+	}
+	return nil
 }
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		data   string
+		strict bool
+		want   string
+	}{
+		{"{\n  \"MongoUrl\": {\n    \"Endpoints\": [\"a:1\",]\n  }\n}", false, "<memory>:3:"},
+		{"{\n  \"MongoUrI\": {}\n}", true, `<memory>:2:3: unknown field "MongoUrI"`},
+		{"{\n  \"MongoUrl\": {\n    \"Endpoints\": [\"a\"],\n    \"ConnectTimeout\": \"30\"\n  }\n}", false, "<memory>:4:5: MongoUrl.ConnectTimeout: cannot use"},
+		{`{"MongoUrl":{"Endpoints":[]}}`, false, `<memory>: MongoUrl: Endpoints must not be empty`},
+		{`{"mongoUrl":{"Endpoints":[]}}`, false, `<memory>: mongoUrl: Endpoints must not be empty`},
+		{`{"MongoUrl":{"a":{"Endpoints":["a"]},"b":{"Endpoints":[]}}}`, false, `<memory>: MongoUrl.b: Endpoints must not be empty`},
+		{`{"MongoUrl":{"ReplicaSet":"rs0","ConnectTimeout":10}}`, false, `<memory>: MongoUrl: Endpoints must not be empty`},
+		{"{\n  \"MongoUrl\": {\n    \"Endpoints\": [\"a\"],\n    \"Pasword\": \"x\"\n  }\n}", true, `<memory>:4:5: unknown field "Pasword"`},
+		{`{"MongoUrl":{"Endpoints":["::1"]}}`, false, "IPv6 addresses must be in brackets"},
+		{`{"MongoUrl":{"Endpoints":["a:99999"]}}`, false, "bad port"},
+		{`{"MongoUrl":{"Endpoints":["a"],"MinConnectionPoolSize":10,"MaxConnectionPoolSize":5}}`, false, "exceeds MaxConnectionPoolSize"},
+	}
+
+	for _, c := range cases {
+		v := &mongoConfig{}
+		source := NewBytesSource([]byte(c.data))
+		var opts []Option
+		if c.strict {
+			opts = append(opts, Strict())
+		}
+		_, err := LoadSource(source, &v, opts...)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want %q", c.data, err, c.want)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	v := &mongoConfig{}
+	_, err := LoadSource(NewBytesSource([]byte(`{"MongoUrl":{"Endpoints":["[::1]:27017"]},"Extra":1}`)), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
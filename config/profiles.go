@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const defaultProfile = "default"
+
+// treeNormalizer is implemented by config values that reshape the decoded
+// document before it is bound to them.
+type treeNormalizer interface {
+	normalizeTree(tree interface{}) interface{}
+}
+
+// pathRewriter is implemented by treeNormalizers that move keys, so that
+// errors name the field paths the user wrote.
+type pathRewriter interface {
+	writtenPath(path string) string
+}
+
+// profileSection records how a profile section was written, so that errors
+// name what the user wrote rather than the normalized tree.
+type profileSection struct {
+	key    string // the section key as written
+	single bool   // a single node that became the default profile
+}
+
+// path returns the path of profile in the section as it was written
+func (s profileSection) path(profile string) string {
+	if s.single {
+		return s.key
+	}
+	return s.key + "." + profile
+}
+
+// field rewrites a dotted field path of the normalized tree, such as
+// MongoUrl.default.ConnectTimeout, into the path as it was written
+func (s profileSection) field(path string) string {
+	parts := strings.Split(path, ".")
+	if !strings.EqualFold(parts[0], s.key) {
+		return path
+	}
+	parts[0] = s.key
+	if s.single && len(parts) > 1 && parts[1] == defaultProfile {
+		parts = append(parts[:1], parts[2:]...)
+	}
+	return strings.Join(parts, ".")
+}
+
+// normalizeProfiles reshapes the connection section called section in tree
+// into named profiles. A section that is itself a node, recognized by its
+// Endpoints key or by values that are not objects, becomes the default
+// profile, so single-node files keep working and report their own errors.
+// Every profile is laid over the fields of defaults.
+func normalizeProfiles(tree interface{}, section string, defaults interface{}) (interface{}, profileSection) {
+	written := profileSection{key: section}
+	m, ok := tree.(map[string]interface{})
+	if !ok {
+		return tree, written
+	}
+
+	for key, value := range m {
+		if !strings.EqualFold(key, section) {
+			continue
+		}
+		profiles, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		written.key = key
+		if isNode(profiles) {
+			profiles = map[string]interface{}{defaultProfile: profiles}
+			written.single = true
+		}
+		for name, profile := range profiles {
+			if node, ok := profile.(map[string]interface{}); ok {
+				profiles[name] = withDefaults(defaults, node)
+			}
+		}
+		m[key] = profiles
+	}
+	return m, written
+}
+
+// isNode reports whether section is a single node rather than a map of
+// named profiles, whose values are all objects
+func isNode(section map[string]interface{}) bool {
+	if findKey(section, "Endpoints") != "" {
+		return true
+	}
+	for _, value := range section {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaults returns node laid over the JSON fields of defaults. Keys
+// are matched case-insensitively, as encoding/json binds them.
+func withDefaults(defaults interface{}, node map[string]interface{}) map[string]interface{} {
+	var out map[string]interface{}
+	buf, err := json.Marshal(defaults)
+	if err != nil || json.Unmarshal(buf, &out) != nil || out == nil {
+		return node
+	}
+
+	for k, v := range node {
+		if existing := findKey(out, k); existing != "" {
+			delete(out, existing)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func findKey(m map[string]interface{}, key string) string {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return ""
+}
+
+// writtenPath maps a field path of the normalized tree of v back to the
+// path that was written
+func writtenPath(v interface{}, path string) string {
+	if r, ok := firstImplementing(v, (*pathRewriter)(nil)).(pathRewriter); ok {
+		return r.writtenPath(path)
+	}
+	return path
+}
+
+// normalize calls normalizeTree on the first value along the pointer chain
+// of v that implements treeNormalizer.
+func normalize(v interface{}, tree interface{}) interface{} {
+	if n, ok := firstImplementing(v, (*treeNormalizer)(nil)).(treeNormalizer); ok {
+		return n.normalizeTree(tree)
+	}
+	return tree
+}
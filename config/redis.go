@@ -1,7 +1,14 @@
 package config
 
-import "bytes"
-import "strconv"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const (
 	sharedRedisFilename = "redis.json"
@@ -9,7 +16,20 @@ const (
 )
 
 type redisConfig struct {
-	RedisConnectionStrings *RedisNode
+	RedisConnectionStrings redisProfiles
+
+	written profileSection
+}
+
+type redisProfiles map[string]*RedisNode
+
+func (c *redisConfig) normalizeTree(tree interface{}) interface{} {
+	tree, c.written = normalizeProfiles(tree, redisSectionName, newRedisNode())
+	return tree
+}
+
+func (c *redisConfig) writtenPath(path string) string {
+	return c.written.field(path)
 }
 
 // RedisNode is
@@ -37,7 +57,59 @@ func newRedisNode() *RedisNode {
 	}
 }
 
-func (n *RedisNode) build() string {
+// Validate implements Validator
+func (c *redisConfig) Validate() error {
+	if len(c.RedisConnectionStrings) == 0 {
+		return fmt.Errorf("%s is required", redisSectionName)
+	}
+	for _, name := range c.profiles() {
+		if err := c.RedisConnectionStrings[name].Validate(); err != nil {
+			return fmt.Errorf("%s: %s", c.written.path(name), err.Error())
+		}
+	}
+	return nil
+}
+
+func (c *redisConfig) profiles() []string {
+	names := make([]string, 0, len(c.RedisConnectionStrings))
+	for name := range c.RedisConnectionStrings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks endpoints and timeouts
+func (n *RedisNode) Validate() error {
+	if len(n.Endpoints) == 0 {
+		return errors.New("Endpoints must not be empty")
+	}
+	for _, e := range n.Endpoints {
+		if err := validateEndpoint(e, false); err != nil {
+			return err
+		}
+	}
+
+	if strings.Contains(n.Password, ",") {
+		return errors.New("Password must not contain ','")
+	}
+	if n.SyncTimeout < 0 || n.ConnectTimeout < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	if n.ConnectRetry < 0 || n.WriteBuffer < 0 {
+		return errors.New("ConnectRetry and WriteBuffer must not be negative")
+	}
+	return nil
+}
+
+// String implements fmt.Stringer
+func (n *RedisNode) String() string {
+	return n.ConnectionString()
+}
+
+// ConnectionString returns a StackExchange.Redis style configuration string,
+// or an empty string when n has no endpoints.
+func (n *RedisNode) ConnectionString() string {
 	buffer := bytes.NewBufferString("")
 	if len(n.Endpoints) == 0 {
 		return buffer.String()
@@ -79,6 +151,64 @@ func (n *RedisNode) append(buffer *bytes.Buffer, prefix string, value interface{
 	}
 }
 
+// ParseRedisConnectionString parses a StackExchange.Redis style
+// configuration string such as "host:6379,password=secret,abortConnect=false".
+// Option names are case-insensitive and may appear in any order. Options
+// that RedisNode has no field for, such as ssl or defaultDatabase, are
+// ignored.
+func ParseRedisConnectionString(s string) (*RedisNode, error) {
+	n := newRedisNode()
+	for _, token := range strings.Split(s, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		i := strings.Index(token, "=")
+		if i < 0 {
+			n.Endpoints = append(n.Endpoints, token)
+			continue
+		}
+		if i == 0 {
+			return nil, fmt.Errorf("redis connection string: option %q has no name", token)
+		}
+		if err := n.setOption(token[:i], token[i+1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(n.Endpoints) == 0 {
+		return nil, fmt.Errorf("redis connection string %q has no endpoints", s)
+	}
+	return n, nil
+}
+
+func (n *RedisNode) setOption(key, value string) (err error) {
+	switch strings.ToLower(key) {
+	case "password":
+		n.Password = value
+	case "keepalive":
+		n.KeepAlive, err = strconv.Atoi(value)
+	case "synctimeout":
+		n.SyncTimeout, err = strconv.Atoi(value)
+	case "allowadmin":
+		n.AllowAdmin, err = strconv.ParseBool(value)
+	case "connecttimeout":
+		n.ConnectTimeout, err = strconv.Atoi(value)
+	case "writebuffer":
+		n.WriteBuffer, err = strconv.Atoi(value)
+	case "abortconnect":
+		n.AbortOnConnectFail, err = strconv.ParseBool(value)
+	case "connectretry":
+		n.ConnectRetry, err = strconv.Atoi(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("redis connection string: option %s: %s", key, err.Error())
+	}
+	return nil
+}
+
 func getValue(value interface{}) string {
 	switch v := value.(type) {
 	case string:
@@ -91,18 +221,63 @@ func getValue(value interface{}) string {
 	return ""
 }
 
-// GetRedisNode returns redis node
+func loadRedisConfig() (*redisConfig, error) {
+	c := &redisConfig{}
+	_, err := Load(sharedRedisFilename, &c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetRedisNode returns the default redis node
 func GetRedisNode() (*RedisNode, error) {
-	n := newRedisNode()
-	c := &redisConfig{
-		RedisConnectionStrings: n,
+	return GetRedisNodeProfile(defaultProfile)
+}
+
+// GetRedisNodeProfile returns the named redis node, "default" if profile is empty
+func GetRedisNodeProfile(profile string) (*RedisNode, error) {
+	c, err := loadRedisConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := Load(sharedRedisFilename, &c)
+	if profile == "" {
+		profile = defaultProfile
+	}
+
+	n, ok := c.RedisConnectionStrings[profile]
+	if !ok {
+		return nil, fmt.Errorf("redis profile %q: not found in %s", profile, redisSectionName)
+	}
+	return n, nil
+}
+
+// GetRedisProfiles returns the sorted profile names in redis.json
+func GetRedisProfiles() ([]string, error) {
+	c, err := loadRedisConfig()
 	if err != nil {
 		return nil, err
 	}
+	return c.profiles(), nil
+}
 
-	node := c.RedisConnectionStrings
-	return node, nil
+// WatchRedis loads redis.json, checks it for changes every interval and
+// calls fn with the profiles before and after each change. It returns the
+// current profiles and the File, whose Close stops watching and whose
+// OnError receives failed reloads; the profiles passed to fn are not
+// modified afterwards.
+func WatchRedis(interval time.Duration, fn func(old, new map[string]*RedisNode)) (map[string]*RedisNode, *File, error) {
+	c := &redisConfig{}
+	f, err := Load(sharedRedisFilename, &c)
+	if err != nil {
+		return nil, nil, err
+	}
+	f.OnChange(func(old, new interface{}) {
+		fn((*old.(**redisConfig)).RedisConnectionStrings, (*new.(**redisConfig)).RedisConnectionStrings)
+	})
+	if err := f.Watch(interval); err != nil {
+		return nil, nil, err
+	}
+	return c.RedisConnectionStrings, f, nil
 }
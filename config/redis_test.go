@@ -1,15 +1,93 @@
 package config
 
 import (
-	"fmt"
+	"os"
+	"reflect"
 	"testing"
 )
 
 func TestRedis(t *testing.T) {
+	name := envSourceName(sharedRedisFilename)
+	defer os.Setenv(name, os.Getenv(name))
+	os.Setenv(name, `{"RedisConnectionStrings":{"Endpoints":["10.0.0.1:6379"]}}`)
+
 	s, err := GetRedisNode()
 	if err != nil {
-		t.Errorf("Get redis connection string error: %s", err.Error())
+		t.Fatalf("Get redis connection string error: %s", err.Error())
+	}
+
+	if len(s.Endpoints) != 1 || s.Endpoints[0] != "10.0.0.1:6379" {
+		t.Errorf("Endpoints = %v", s.Endpoints)
+	}
+}
+
+func TestRedisConnectionStringRoundTrip(t *testing.T) {
+	n := newRedisNode()
+	n.Endpoints = []string{"10.0.0.1:6379", "[::1]:6380"}
+	n.Password = "s3cr=t@!"
+	n.AllowAdmin = true
+	n.AbortOnConnectFail = false
+	n.KeepAlive = 60
+
+	parsed, err := ParseRedisConnectionString(n.ConnectionString())
+	if err != nil {
+		t.Fatal(err)
 	}
+	if !reflect.DeepEqual(n, parsed) {
+		t.Errorf("round trip mismatch:\n%+v\n%+v", n, parsed)
+	}
+
+	reordered, err := ParseRedisConnectionString("abortConnect=false, password=s3cr=t@!,10.0.0.1:6379,allowAdmin=true,[::1]:6380,KeepAlive=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, reordered) {
+		t.Errorf("option order changed the result:\n%+v\n%+v", n, reordered)
+	}
+}
 
-	fmt.Println(s.Endpoints)
+func TestParseRedisConnectionStringUnknownOptions(t *testing.T) {
+	n, err := ParseRedisConnectionString("h:6379,ssl=true,defaultDatabase=2,password=p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Endpoints) != 1 || n.Endpoints[0] != "h:6379" || n.Password != "p" {
+		t.Errorf("unexpected node %+v", n)
+	}
+
+	for _, s := range []string{"h:6379,=2", "h:6379,keepAlive=x"} {
+		if _, err := ParseRedisConnectionString(s); err == nil {
+			t.Errorf("ParseRedisConnectionString(%q) succeeded", s)
+		}
+	}
+}
+
+func TestRedisProfiles(t *testing.T) {
+	RegisterSource(sharedRedisFilename, NewBytesSource([]byte(`{
+		"RedisConnectionStrings": {
+			"default": {"Endpoints": ["10.0.0.1:6379"]},
+			"session": {"Endpoints": ["10.0.0.2:6379"], "SyncTimeout": 3000}
+		}
+	}`)))
+	defer RegisterSource(sharedRedisFilename, nil)
+
+	profiles, err := GetRedisProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(profiles, []string{"default", "session"}) {
+		t.Errorf("profiles = %v", profiles)
+	}
+
+	n, err := GetRedisNodeProfile("session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Endpoints[0] != "10.0.0.2:6379" || n.SyncTimeout != 3000 || n.ConnectTimeout != 5000 {
+		t.Errorf("unexpected session node: %+v", n)
+	}
+
+	if _, err := GetRedisNodeProfile("queue"); err == nil {
+		t.Error("expected error for missing profile")
+	}
 }
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// consts
+const (
+	// KeyFileEnvName names the environment variable that overrides the key
+	// file used to decrypt "enc:" values, which defaults to secret.key in the
+	// shared config directory.
+	KeyFileEnvName = "FEINIUBUS_KEY_FILE"
+
+	// EncryptedPrefix marks a config value encrypted with Encrypt.
+	EncryptedPrefix = "enc:"
+
+	secretKeyFilename = "secret.key"
+	secretKeySize     = 32
+)
+
+var (
+	secretRefPattern      = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+	encryptedValuePattern = regexp.MustCompile(`enc:[A-Za-z0-9+/]+=*`)
+)
+
+// KeyFile returns the path of the key file used to decrypt "enc:" values
+func KeyFile() (string, error) {
+	if name := os.Getenv(KeyFileEnvName); name != "" {
+		return name, nil
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, secretKeyFilename), nil
+}
+
+// GenerateKey returns a new random AES-256 key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, secretKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ReadKey reads a base64 encoded key from the named file
+func ReadKey(name string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid key: %s", name, err.Error())
+	}
+	if len(key) != secretKeySize {
+		return nil, fmt.Errorf("%s: key must be %d bytes", name, secretKeySize)
+	}
+	return key, nil
+}
+
+// WriteKey writes key base64 encoded to the named file, readable only by
+// its owner. The key is written to a temporary file that is then renamed,
+// so the file never holds a partial key.
+func WriteKey(name string, key []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+	if err == nil {
+		err = f.Chmod(0600)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Encrypt seals plaintext with AES-GCM and returns an "enc:" config value
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens an "enc:" config value produced by Encrypt
+func Decrypt(key []byte, value string) (string, error) {
+	if !strings.HasPrefix(value, EncryptedPrefix) {
+		return "", errors.New("config: value is not encrypted")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(value[len(EncryptedPrefix):])
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("config: encrypted value is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("config: cannot decrypt value, wrong key or corrupted data")
+	}
+	return string(plaintext), nil
+}
+
+// Reencrypt rewrites every "enc:" value in a config document from oldKey to
+// newKey, leaving the rest of the document untouched. Only whole values are
+// rewritten, not "enc:" appearing inside other text.
+func Reencrypt(data, oldKey, newKey []byte) ([]byte, error) {
+	var out []byte
+	last := 0
+	for _, loc := range encryptedValuePattern.FindAllIndex(data, -1) {
+		start, end := loc[0], loc[1]
+		if !isWholeValue(data, start, end) {
+			continue
+		}
+		plaintext, err := Decrypt(oldKey, string(data[start:end]))
+		if err != nil {
+			return nil, err
+		}
+		value, err := Encrypt(newKey, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(out, data[last:start]...), value...)
+		last = end
+	}
+	return append(out, data[last:]...), nil
+}
+
+// isWholeValue reports whether data[start:end] is a complete scalar: quoted,
+// or unquoted between a key, list or flow delimiter and the end of the line
+// or the next delimiter.
+func isWholeValue(data []byte, start, end int) bool {
+	if start > 0 && (data[start-1] == '"' || data[start-1] == '\'') {
+		return end < len(data) && data[end] == data[start-1]
+	}
+
+	i := skipBlanks(data, start)
+	if i > 0 {
+		switch data[i-1] {
+		case '\n', '=', '[', ',', '{':
+		case ':':
+			// YAML needs a space after the colon of a key
+			if i == start {
+				return false
+			}
+		case '-':
+			// a block sequence entry starts its line
+			if i == start {
+				return false
+			}
+			if j := skipBlanks(data, i-1); j > 0 && data[j-1] != '\n' {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return end == len(data) || strings.IndexByte("\r\n,]}#", data[end]) >= 0
+}
+
+// skipBlanks returns the offset before the spaces and tabs that end at i
+func skipBlanks(data []byte, i int) int {
+	for i > 0 && (data[i-1] == ' ' || data[i-1] == '\t') {
+		i--
+	}
+	return i
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretResolver replaces secret references in decoded config values. The
+// key file is only read once an encrypted value is found.
+type secretResolver struct {
+	key []byte
+}
+
+func (r *secretResolver) resolve(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			resolved, err := r.resolve(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", k, err.Error())
+			}
+			t[k] = resolved
+		}
+	case []interface{}:
+		for i, item := range t {
+			resolved, err := r.resolve(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %s", i, err.Error())
+			}
+			t[i] = resolved
+		}
+	case string:
+		return r.resolveString(t)
+	}
+	return v, nil
+}
+
+func (r *secretResolver) resolveString(s string) (string, error) {
+	if strings.HasPrefix(s, EncryptedPrefix) {
+		if r.key == nil {
+			name, err := KeyFile()
+			if err != nil {
+				return "", err
+			}
+			if r.key, err = ReadKey(name); err != nil {
+				return "", err
+			}
+		}
+		return Decrypt(r.key, s)
+	}
+
+	var err error
+	resolved := secretRefPattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := secretRefPattern.FindStringSubmatch(m)
+		switch parts[1] {
+		case "env":
+			value, ok := os.LookupEnv(parts[2])
+			if !ok && err == nil {
+				err = fmt.Errorf("environment variable %s is not set", parts[2])
+			}
+			return value
+		default:
+			buf, e := ioutil.ReadFile(parts[2])
+			if e != nil && err == nil {
+				err = e
+			}
+			return string(bytes.TrimRight(buf, "\r\n"))
+		}
+	})
+	return resolved, err
+}
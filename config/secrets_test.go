@@ -0,0 +1,105 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feiniubus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := GenerateKey()
+	keyFile := filepath.Join(dir, "secret.key")
+	if err := WriteKey(keyFile, key); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv(KeyFileEnvName, os.Getenv(KeyFileEnvName))
+	os.Setenv(KeyFileEnvName, keyFile)
+
+	secretFile := filepath.Join(dir, "redis")
+	ioutil.WriteFile(secretFile, []byte("from-file\n"), 0600)
+	defer os.Unsetenv("FEINIUBUS_TEST_MONGO_PWD")
+	os.Setenv("FEINIUBUS_TEST_MONGO_PWD", "from-env")
+	encrypted, err := Encrypt(key, "from-enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Env, File, Enc, Mixed string
+		Port                  int
+	}
+	data := `{"Env":"${env:FEINIUBUS_TEST_MONGO_PWD}","File":"${file:` + secretFile + `}",` +
+		`"Enc":"` + encrypted + `","Mixed":"a-${env:FEINIUBUS_TEST_MONGO_PWD}-b","Port":27017}`
+	if _, err := LoadSource(NewBytesSource([]byte(data)), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Env != "from-env" || v.File != "from-file" || v.Enc != "from-enc" || v.Mixed != "a-from-env-b" || v.Port != 27017 {
+		t.Errorf("unexpected values: %+v", v)
+	}
+
+	if _, err := LoadSource(NewBytesSource([]byte(`{"Env":"${env:FEINIUBUS_TEST_UNSET}"}`)), &v); err == nil {
+		t.Error("expected error for unset variable")
+	}
+
+	newKey, _ := GenerateKey()
+	rotated, err := Reencrypt([]byte(data), key, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WriteKey(keyFile, newKey)
+	v.Enc = ""
+	if _, err := LoadSource(NewBytesSource(rotated), &v); err != nil || v.Enc != "from-enc" {
+		t.Errorf("rotated value = %q, err = %v", v.Enc, err)
+	}
+}
+
+func TestReencryptWholeValues(t *testing.T) {
+	oldKey, _ := GenerateKey()
+	newKey, _ := GenerateKey()
+	enc, err := Encrypt(oldKey, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := []string{
+		"a: " + enc,
+		`b: "` + enc + `"`,
+		"c: [" + enc + ", '" + enc + "']",
+		"d:",
+		"  - " + enc + " # comment",
+		"e = " + enc,
+		"note: copied from ..." + enc,
+		"text: see " + enc,
+		"url: http://h/" + enc + "/x",
+	}
+	rotated, err := Reencrypt([]byte(strings.Join(lines, "\n")), oldKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, line := range strings.Split(string(rotated), "\n") {
+		want := 0
+		if i < 6 && i != 3 {
+			want = strings.Count(lines[i], enc)
+		}
+		if n := strings.Count(line, EncryptedPrefix) - strings.Count(line, enc); n != want {
+			t.Errorf("line %q: %d values rewritten, want %d", line, n, want)
+		}
+	}
+	for _, m := range encryptedValuePattern.FindAllString(string(rotated), -1) {
+		if m == enc {
+			continue
+		}
+		if s, err := Decrypt(newKey, m); err != nil || s != "secret" {
+			t.Errorf("Decrypt(%s) = %q, %v", m, s, err)
+		}
+	}
+}
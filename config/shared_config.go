@@ -1,31 +1,72 @@
 package config
 
 import (
+	"bytes"
 	"errors"
-	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FeiniuBus/ecosystem/hosting"
 )
 
-type dataSource interface {
-	ReadCloser() (io.ReadCloser, error)
-}
+// ConfigDirEnvName names the environment variable that overrides the
+// shared config directory, which defaults to ~/.feiniubus.
+const ConfigDirEnvName = "FEINIUBUS_CONFIG_DIR"
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]DataSource)
+)
 
-type sourceFile struct {
-	name string
+// RegisterSource makes Load read name from source instead of the shared
+// config directory. A nil source removes the registration.
+func RegisterSource(name string, source DataSource) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if source == nil {
+		delete(registry, name)
+		return
+	}
+	registry[name] = source
 }
 
-func (s sourceFile) ReadCloser() (io.ReadCloser, error) {
-	return os.Open(s.name)
+// Option configures how a File is loaded.
+type Option func(*File)
+
+// Strict rejects fields in the data source that the value does not have.
+func Strict() Option {
+	return func(f *File) {
+		f.strict = true
+	}
 }
 
+// ChangeFunc is called after a File swapped in a new value.
+type ChangeFunc func(old, new interface{})
+
 // File represents a combination of a json file in memory.
 type File struct {
-	dataSource dataSource
+	dataSource DataSource
 	v          interface{}
+	strict     bool
+	format     Format
+	defaultFmt Format
+	defaults   reflect.Value // copy of the value passed to Load before parsing
+	value      atomic.Value
+	reloading  sync.Mutex // serializes reloads
+
+	mu       sync.Mutex // guards v, raw, handlers, onError and stop
+	raw      []byte
+	handlers []ChangeFunc
+	onError  func(error)
+	stop     chan struct{}
 }
 
-func newFile(source dataSource, v interface{}) *File {
+func newFile(source DataSource, v interface{}) *File {
 	return &File{
 		dataSource: source,
 		v:          v,
@@ -33,49 +74,300 @@ func newFile(source dataSource, v interface{}) *File {
 }
 
 // Load returns a new File pointer
-func Load(name string, v interface{}) (*File, error) {
+func Load(name string, v interface{}, opts ...Option) (*File, error) {
 	source, err := parseDataSource(name)
 	if err != nil {
 		return nil, err
 	}
 
 	f := newFile(source, v)
-	if err := f.Reload(); err != nil {
-		return nil, err
+	f.defaultFmt = formatOf(name)
+	return f, f.init(opts)
+}
+
+// LoadSource returns a new File pointer that reads from source
+func LoadSource(source DataSource, v interface{}, opts ...Option) (*File, error) {
+	f := newFile(source, v)
+	return f, f.init(opts)
+}
+
+func (f *File) init(opts []Option) error {
+	for _, opt := range opts {
+		opt(f)
 	}
+	return f.load()
+}
+
+// Value returns the current value of f. It is the value passed to Load
+// until f is reloaded, after which it is a fresh value of the same type
+// that is never modified, so it is safe to read while f is watched.
+func (f *File) Value() interface{} {
+	return f.value.Load()
+}
 
-	return f, nil
+// OnChange registers fn to be called whenever a reload yields different
+// content. Callbacks run on the goroutine that performed the reload.
+func (f *File) OnChange(fn ChangeFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, fn)
 }
 
-// Reload reloads and parses data source
+// OnError registers fn to be called when a watched reload fails. The
+// previous value stays in place.
+func (f *File) OnError(fn func(error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onError = fn
+}
+
+// Reload reloads and parses data source into a fresh value and swaps it in.
+// The value passed to Load is updated as well, unlike when f is watched.
 func (f *File) Reload() (err error) {
-	if err := f.reload(f.dataSource); err != nil {
+	v, err := f.reload(true)
+	if err != nil {
 		return err
 	}
 
+	f.mu.Lock()
+	reflect.ValueOf(f.v).Elem().Set(deepCopy(reflect.ValueOf(v).Elem()))
+	f.mu.Unlock()
 	return nil
 }
 
-func (f *File) reload(s dataSource) error {
-	r, err := s.ReadCloser()
+// Watch polls the data source every interval and reloads f when its
+// content changes. It returns an error if f is already being watched.
+func (f *File) Watch(interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("config: watch interval must be positive")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stop != nil {
+		return errors.New("config: file is already watched")
+	}
+
+	stop := make(chan struct{})
+	f.stop = stop
+	go f.watch(interval, stop)
+	return nil
+}
+
+// Close stops watching the data source.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stop != nil {
+		close(f.stop)
+		f.stop = nil
+	}
+	return nil
+}
+
+func (f *File) watch(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := f.reload(false); err != nil {
+				f.mu.Lock()
+				onError := f.onError
+				f.mu.Unlock()
+				if onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}
+}
+
+func (f *File) load() error {
+	t := reflect.TypeOf(f.v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return errors.New("config: value must be a non-nil pointer")
+	}
+
+	f.defaults = deepCopy(reflect.ValueOf(f.v).Elem())
+
+	raw, tree, name, format, err := f.read(f.dataSource)
 	if err != nil {
 		return err
 	}
+	if err := f.parse(name, format, raw, tree, f.v); err != nil {
+		return err
+	}
+
+	f.raw = raw
+	f.value.Store(f.v)
+	return nil
+}
+
+// reload re-reads the data source and returns the value now current.
+// Unless force is set, nothing is parsed when the content is unchanged
+// since the last read.
+func (f *File) reload(force bool) (interface{}, error) {
+	f.reloading.Lock()
+	defer f.reloading.Unlock()
+
+	raw, tree, name, format, err := f.read(f.dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	changed := !bytes.Equal(raw, f.raw)
+	f.mu.Unlock()
+	if !changed && !force {
+		return f.value.Load(), nil
+	}
+
+	v := f.fresh()
+	if err := f.parse(name, format, raw, tree, v); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.raw = raw
+	old := f.value.Load()
+	f.value.Store(v)
+	handlers := make([]ChangeFunc, len(f.handlers))
+	copy(handlers, f.handlers)
+	f.mu.Unlock()
+
+	if changed {
+		for _, fn := range handlers {
+			fn(old, v)
+		}
+	}
+	return v, nil
+}
+
+// fresh returns a new value of the same type as the one passed to Load,
+// carrying the same defaults it had before the first parse.
+func (f *File) fresh() interface{} {
+	v := reflect.New(f.defaults.Type())
+	v.Elem().Set(deepCopy(f.defaults))
+	return v.Interface()
+}
+
+// read returns the content of s, the name of what was read when the reader
+// knows it, and the format to decode it with. A format reported by the
+// reader wins over WithFormat, which wins over the extension of the name.
+// A reader that already decoded the content also returns its tree.
+func (f *File) read(s DataSource) ([]byte, interface{}, string, Format, error) {
+	r, err := s.ReadCloser()
+	if err != nil {
+		return nil, nil, "", "", err
+	}
 	defer r.Close()
 
-	return f.parse(r)
+	var name string
+	if n, ok := r.(interface {
+		Name() string
+	}); ok {
+		name = n.Name()
+	}
+
+	format := f.format
+	if fr, ok := r.(interface {
+		Format() Format
+	}); ok && fr.Format() != "" {
+		format = fr.Format()
+	}
+	if format == "" {
+		format = formatOf(name)
+	}
+	if format == "" {
+		format = f.defaultFmt
+	}
+
+	var tree interface{}
+	if tr, ok := r.(interface {
+		Tree() interface{}
+	}); ok {
+		tree = tr.Tree()
+	}
+
+	buf, err := newParser(r).ReadAll()
+	return buf, tree, name, format, err
 }
 
-func parseDataSource(filename string) (dataSource, error) {
+func parseDataSource(filename string) (DataSource, error) {
+	registryMu.RLock()
+	source, ok := registry[filename]
+	registryMu.RUnlock()
+	if ok {
+		return source, nil
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := findSharedFile(dir, filename)
+	env := strings.ToLower(hosting.EnvironmentName)
+	if env == "" {
+		source = NewFileSource(path)
+	} else {
+		stem := strings.TrimSuffix(path, filepath.Ext(path))
+		source = overlaySource{base: path, overlay: stem + "." + env}
+	}
+
+	return NewChainSource(NewEnvSource(envSourceName(filename)), source), nil
+}
+
+// findSharedFile returns the path of filename in dir. When it does not exist
+// the same name with another supported extension is tried, so redis.json
+// also finds redis.yaml or redis.toml.
+func findSharedFile(dir, filename string) string {
+	path := filepath.Join(dir, filename)
+	if _, err := os.Stat(path); err == nil || formatOf(filename) == "" {
+		return path
+	}
+
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range sharedExtensions {
+		if _, err := os.Stat(stem + ext); err == nil {
+			return stem + ext
+		}
+	}
+	return path
+}
+
+// envSourceName maps a shared config filename to the environment variable
+// that may carry its content, e.g. mongo.json to FEINIUBUS_MONGO_JSON.
+func envSourceName(filename string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, filename)
+	return "FEINIUBUS_" + name
+}
+
+func configDir() (string, error) {
+	if dir := os.Getenv(ConfigDirEnvName); dir != "" {
+		return dir, nil
+	}
+
 	homeDir := os.Getenv("HOME")
 	if homeDir == "" {
 		homeDir = os.Getenv("USERPROFILE")
 	}
 
 	if homeDir == "" {
-		return nil, errors.New("user home directory not found")
+		return "", errors.New("user home directory not found")
 	}
 
-	path := filepath.Join(homeDir, ".feiniubus", filename)
-	return sourceFile{path}, nil
+	return filepath.Join(homeDir, ".feiniubus"), nil
 }
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FeiniuBus/ecosystem/hosting"
+)
+
+func TestLoadEnvironmentOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feiniubus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(ConfigDirEnvName, os.Getenv(ConfigDirEnvName))
+	os.Setenv(ConfigDirEnvName, dir)
+	defer func(env string) { hosting.EnvironmentName = env }(hosting.EnvironmentName)
+	hosting.EnvironmentName = "Staging"
+
+	base := `{"MongoUrl":{"Endpoints":["db1:27017"],"Username":"app","ReplicaSet":"rs0"}}`
+	overlay := `{"MongoUrl":{"Endpoints":["staging:27017"],"Password":"secret"}}`
+	ioutil.WriteFile(filepath.Join(dir, "mongo.json"), []byte(base), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "mongo.staging.json"), []byte(overlay), 0600)
+
+	n, err := GetMongoDialInfo("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Endpoints) != 1 || n.Endpoints[0] != "staging:27017" {
+		t.Errorf("Endpoints = %v, want overlay value", n.Endpoints)
+	}
+	if n.Username != "app" || n.ReplicaSet != "rs0" || n.Password != "secret" {
+		t.Errorf("keys were not deep-merged: %+v", n)
+	}
+	if n.ConnectTimeout != DefaultMongoConnectTimeout {
+		t.Errorf("ConnectTimeout = %d, want default", n.ConnectTimeout)
+	}
+}
+
+func TestLoadEnvironmentOverlayOtherExtension(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feiniubus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(ConfigDirEnvName, os.Getenv(ConfigDirEnvName))
+	os.Setenv(ConfigDirEnvName, dir)
+	defer func(env string) { hosting.EnvironmentName = env }(hosting.EnvironmentName)
+	hosting.EnvironmentName = "Staging"
+
+	base := `{"MongoUrl":{"Endpoints":["db1:27017"],"Username":"app"}}`
+	overlay := "MongoUrl:\n  Endpoints: [\"staging:27017\"]\n  Password: secret\n"
+	ioutil.WriteFile(filepath.Join(dir, "mongo.json"), []byte(base), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "mongo.staging.yaml"), []byte(overlay), 0600)
+
+	n, err := GetMongoDialInfo("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Endpoints) != 1 || n.Endpoints[0] != "staging:27017" {
+		t.Errorf("Endpoints = %v, want overlay value", n.Endpoints)
+	}
+	if n.Username != "app" || n.Password != "secret" {
+		t.Errorf("keys were not deep-merged: %+v", n)
+	}
+}
+
+func TestLoadEnvironmentOverlayWithoutBase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feiniubus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(ConfigDirEnvName, os.Getenv(ConfigDirEnvName))
+	os.Setenv(ConfigDirEnvName, dir)
+	defer func(env string) { hosting.EnvironmentName = env }(hosting.EnvironmentName)
+	hosting.EnvironmentName = "Staging"
+
+	overlay := "[MongoUrl]\nEndpoints = [\"staging:27017\"]\n"
+	ioutil.WriteFile(filepath.Join(dir, "mongo.staging.toml"), []byte(overlay), 0600)
+
+	n, err := GetMongoDialInfo("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Endpoints) != 1 || n.Endpoints[0] != "staging:27017" {
+		t.Errorf("Endpoints = %v, want overlay value", n.Endpoints)
+	}
+}
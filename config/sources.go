@@ -0,0 +1,277 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/FeiniuBus/ecosystem/clearhttp"
+)
+
+// ErrSourceNotFound is returned by a DataSource that has nothing to read.
+// A chain source moves on to the next source when it sees this error.
+var ErrSourceNotFound = errors.New("config: data source not found")
+
+// DataSource provides the raw content of a config file.
+type DataSource interface {
+	ReadCloser() (io.ReadCloser, error)
+}
+
+// namedReader carries the name reported in parse errors for content that
+// does not come straight from an *os.File, and optionally its format.
+type namedReader struct {
+	io.ReadCloser
+	name   string
+	format Format
+}
+
+func (r namedReader) Name() string {
+	return r.name
+}
+
+func (r namedReader) Format() Format {
+	return r.format
+}
+
+type sourceFile struct {
+	name string
+}
+
+// NewFileSource returns a DataSource that reads the named file
+func NewFileSource(name string) DataSource {
+	return sourceFile{name}
+}
+
+func (s sourceFile) ReadCloser() (io.ReadCloser, error) {
+	return os.Open(s.name)
+}
+
+// overlaySource reads a base file and deep-merges an optional
+// environment-specific file, stem.env with any supported extension, on top
+// of it.
+type overlaySource struct {
+	base    string
+	overlay string // path without extension
+}
+
+// ReadCloser returns a file unchanged when the other one is missing, so
+// that parse errors point into it. Merged content is reported under both
+// file names.
+func (s overlaySource) ReadCloser() (io.ReadCloser, error) {
+	overlay := s.findOverlay()
+	if overlay == "" {
+		return os.Open(s.base)
+	}
+	if _, err := os.Stat(s.base); os.IsNotExist(err) {
+		return os.Open(overlay)
+	}
+
+	base, baseBuf, err := readObject(s.base)
+	if err != nil {
+		return nil, err
+	}
+	over, overBuf, err := readObject(overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	// the raw content only tells a reload whether either file changed
+	raw := append(append(baseBuf, '\n'), overBuf...)
+	name := s.base + "+" + filepath.Base(overlay)
+	return treeReader{
+		namedReader: namedReader{ioutil.NopCloser(bytes.NewReader(raw)), name, ""},
+		tree:        mergeObjects(base, over),
+	}, nil
+}
+
+// findOverlay returns the overlay path with the extension of the base file
+// or else the first supported one that exists, or "" if there is none.
+func (s overlaySource) findOverlay() string {
+	exts := append([]string{filepath.Ext(s.base)}, sharedExtensions...)
+	for _, ext := range exts {
+		if formatOf(ext) == "" {
+			continue
+		}
+		if _, err := os.Stat(s.overlay + ext); err == nil {
+			return s.overlay + ext
+		}
+	}
+	return ""
+}
+
+// treeReader carries content that is already decoded, such as merged
+// overlays. The parser uses the tree instead of decoding the bytes.
+type treeReader struct {
+	namedReader
+	tree interface{}
+}
+
+func (r treeReader) Tree() interface{} {
+	return r.tree
+}
+
+// readObject decodes the named file according to its extension and returns
+// it with the raw content
+func readObject(name string) (map[string]interface{}, []byte, error) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree, offset, err := decodeTree(formatOf(name), buf)
+	if err != nil {
+		e := &ParseError{Path: name, Err: err}
+		if offset >= 0 {
+			e.Line, e.Column = position(buf, offset)
+		}
+		return nil, nil, e
+	}
+
+	m, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, nil, &ParseError{Path: name, Err: errors.New("top-level value must be an object")}
+	}
+	return m, buf, nil
+}
+
+// mergeObjects merges src into dst. Nested objects are merged key by key,
+// any other value in src replaces the one in dst.
+func mergeObjects(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for k, v := range src {
+		sv, ok1 := v.(map[string]interface{})
+		dv, ok2 := dst[k].(map[string]interface{})
+		if ok1 && ok2 {
+			dst[k] = mergeObjects(dv, sv)
+		} else {
+			dst[k] = v
+		}
+	}
+	return dst
+}
+
+type sourceBytes struct {
+	buf []byte
+}
+
+// NewBytesSource returns a DataSource that serves buf from memory
+func NewBytesSource(buf []byte) DataSource {
+	return sourceBytes{buf}
+}
+
+func (s sourceBytes) ReadCloser() (io.ReadCloser, error) {
+	return namedReader{ioutil.NopCloser(bytes.NewReader(s.buf)), "<memory>", ""}, nil
+}
+
+type sourceEnv struct {
+	name string
+}
+
+// NewEnvSource returns a DataSource that reads the content of the named
+// environment variable. An unset or empty variable yields ErrSourceNotFound.
+func NewEnvSource(name string) DataSource {
+	return sourceEnv{name}
+}
+
+func (s sourceEnv) ReadCloser() (io.ReadCloser, error) {
+	value := os.Getenv(s.name)
+	if value == "" {
+		return nil, ErrSourceNotFound
+	}
+	return namedReader{ioutil.NopCloser(bytes.NewBufferString(value)), "$" + s.name, ""}, nil
+}
+
+type sourceHTTP struct {
+	url    string
+	client *http.Client
+
+	mu   sync.Mutex // guards etag and body
+	etag string
+	body []byte
+}
+
+// NewHTTPSource returns a DataSource that fetches url. Responses carrying an
+// ETag are cached and revalidated with If-None-Match. A nil client uses a
+// pooled client from package clearhttp.
+func NewHTTPSource(url string, client *http.Client) DataSource {
+	if client == nil {
+		client = clearhttp.DefaultPooledClient()
+	}
+	return &sourceHTTP{
+		url:    url,
+		client: client,
+	}
+}
+
+func (s *sourceHTTP) ReadCloser() (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		s.etag = resp.Header.Get("ETag")
+		s.body = body
+	case http.StatusNotModified:
+		if s.body == nil {
+			return nil, fmt.Errorf("config: %s: not modified without a cached body", s.url)
+		}
+	case http.StatusNotFound:
+		return nil, ErrSourceNotFound
+	default:
+		return nil, fmt.Errorf("config: %s: %s", s.url, resp.Status)
+	}
+
+	return namedReader{ioutil.NopCloser(bytes.NewReader(s.body)), s.url, ""}, nil
+}
+
+type sourceChain struct {
+	sources []DataSource
+}
+
+// NewChainSource returns a DataSource that tries each source in order and
+// reads from the first one that is found.
+func NewChainSource(sources ...DataSource) DataSource {
+	return sourceChain{sources}
+}
+
+func (s sourceChain) ReadCloser() (io.ReadCloser, error) {
+	err := ErrSourceNotFound
+	for _, source := range s.sources {
+		var r io.ReadCloser
+		r, err = source.ReadCloser()
+		if err == nil {
+			return r, nil
+		}
+		if err != ErrSourceNotFound && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
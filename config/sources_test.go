@@ -0,0 +1,57 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSourceETag(t *testing.T) {
+	var hits, notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"a":1}`))
+	}))
+	defer ts.Close()
+
+	s := NewHTTPSource(ts.URL, nil)
+	for i := 0; i < 2; i++ {
+		r, err := s.ReadCloser()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, _ := ioutil.ReadAll(r)
+		r.Close()
+		if string(buf) != `{"a":1}` {
+			t.Errorf("read %d: got %q", i, buf)
+		}
+	}
+	if hits != 2 || notModified != 1 {
+		t.Errorf("hits = %d, notModified = %d", hits, notModified)
+	}
+}
+
+func TestChainSource(t *testing.T) {
+	s := NewChainSource(
+		NewEnvSource("FEINIUBUS_TEST_UNSET_SOURCE"),
+		NewFileSource("/nonexistent/feiniubus.json"),
+		NewBytesSource([]byte("fallback")),
+	)
+	r, err := s.ReadCloser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	buf, _ := ioutil.ReadAll(r)
+	if string(buf) != "fallback" {
+		t.Errorf("got %q", buf)
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	home, err := ioutil.TempDir("", "feiniubus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	dir := filepath.Join(home, ".feiniubus")
+	os.MkdirAll(dir, 0700)
+	name := filepath.Join(dir, sharedRedisFilename)
+	write := func(password string) {
+		data := `{"RedisConnectionStrings":{"Endpoints":["127.0.0.1:6379"],"Password":"` + password + `"}}`
+		if err := ioutil.WriteFile(name, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old")
+
+	c := &redisConfig{}
+	f, err := Load(sharedRedisFilename, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	changed := make(chan [2]*RedisNode, 1)
+	f.OnChange(func(old, new interface{}) {
+		changed <- [2]*RedisNode{
+			(*old.(**redisConfig)).RedisConnectionStrings[defaultProfile],
+			(*new.(**redisConfig)).RedisConnectionStrings[defaultProfile],
+		}
+	})
+	if err := f.Watch(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	write("new")
+	select {
+	case nodes := <-changed:
+		if nodes[0].Password != "old" || nodes[1].Password != "new" {
+			t.Errorf("unexpected passwords: %q -> %q", nodes[0].Password, nodes[1].Password)
+		}
+		if nodes[1].SyncTimeout != 1000 {
+			t.Errorf("defaults not kept on reload: SyncTimeout = %d", nodes[1].SyncTimeout)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change was not observed")
+	}
+}
+
+func TestReloadUpdatesValue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feiniubus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "app.json")
+	ioutil.WriteFile(name, []byte(`{"A":"old"}`), 0600)
+
+	var v struct {
+		A string
+		B int
+	}
+	v.B = 7
+	f, err := LoadSource(NewFileSource(name), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(name, []byte(`{"A":"new"}`), 0600)
+	if err := f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != "new" || v.B != 7 {
+		t.Errorf("value passed to Load = %+v, want A=new B=7", v)
+	}
+	if current := f.Value(); current == &v {
+		t.Error("Value returned the caller's value after Reload")
+	}
+}
+
+type unmarshalable struct {
+	A    string
+	C    chan int `json:"-"`
+	F    func()   `json:"-"`
+	Self *unmarshalable
+}
+
+func TestLoadUnmarshalableTarget(t *testing.T) {
+	v := &unmarshalable{A: "default", C: make(chan int), F: func() {}}
+	v.Self = v
+	f, err := LoadSource(NewBytesSource([]byte(`{}`)), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != "default" || v.C == nil || v.F == nil || v.Self == nil || v.Self.Self != v.Self {
+		t.Errorf("defaults were not kept: %+v", v)
+	}
+}
+
+func TestWatchRedis(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feiniubus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(ConfigDirEnvName, os.Getenv(ConfigDirEnvName))
+	os.Setenv(ConfigDirEnvName, dir)
+
+	name := filepath.Join(dir, sharedRedisFilename)
+	write := func(password string) {
+		data := `{"RedisConnectionStrings":{"Endpoints":["127.0.0.1:6379"],"Password":"` + password + `"}}`
+		if err := ioutil.WriteFile(name, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old")
+
+	changed := make(chan map[string]*RedisNode, 1)
+	profiles, f, err := WatchRedis(10*time.Millisecond, func(old, new map[string]*RedisNode) {
+		changed <- new
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if profiles[defaultProfile].Password != "old" {
+		t.Errorf("Password = %q, want old", profiles[defaultProfile].Password)
+	}
+
+	write("new")
+	select {
+	case profiles := <-changed:
+		if profiles[defaultProfile].Password != "new" {
+			t.Errorf("Password = %q, want new", profiles[defaultProfile].Password)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change was not observed")
+	}
+}
@@ -0,0 +1,406 @@
+package hosting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrItemNotFound is returned by the accessors of a nil Item, as returned by
+// Section.Item for a missing key.
+var ErrItemNotFound = errors.New("configuration item not found")
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+func (item *Item) value() (string, error) {
+	if item == nil {
+		return "", ErrItemNotFound
+	}
+	return item.Value, nil
+}
+
+// Duration parses the value as a time.Duration, e.g. "30s" or "1h30m".
+func (item *Item) Duration() (time.Duration, error) {
+	s, err := item.value()
+	if err != nil {
+		return 0, err
+	}
+	return time.ParseDuration(strings.TrimSpace(s))
+}
+
+// Size parses the value as a number of bytes, e.g. "512", "64MB" or "1.5G".
+// Units are case-insensitive powers of 1024: B, K, M, G and T, optionally
+// followed by B or iB. Sizes that do not fit in an int64 are an error.
+func (item *Item) Size() (int64, error) {
+	s, err := item.value()
+	if err != nil {
+		return 0, err
+	}
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok || i == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n, err := strconv.ParseInt(s[:i], 10, 64); err == nil {
+		if n > math.MaxInt64/unit {
+			return 0, fmt.Errorf("size %q overflows int64", s)
+		}
+		return n * unit, nil
+	}
+	f, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil && !isRangeError(err) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	// float64(math.MaxInt64) rounds up to 1<<63, the first value out of range
+	if f *= float64(unit); err != nil || f >= float64(math.MaxInt64) {
+		return 0, fmt.Errorf("size %q overflows int64", s)
+	}
+	return int64(f), nil
+}
+
+func isRangeError(err error) bool {
+	e, ok := err.(*strconv.NumError)
+	return ok && e.Err == strconv.ErrRange
+}
+
+// Strings splits a comma-separated value, trimming spaces and dropping
+// empty elements.
+func (item *Item) Strings() ([]string, error) {
+	s, err := item.value()
+	if err != nil {
+		return nil, err
+	}
+	return splitList(s), nil
+}
+
+// Time parses the value as RFC 3339 or as a 2006-01-02 date.
+func (item *Item) Time() (time.Time, error) {
+	s, err := item.value()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseTime(strings.TrimSpace(s))
+}
+
+// URL parses the value as an absolute URL.
+func (item *Item) URL() (*url.URL, error) {
+	s, err := item.value()
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("invalid url %q: missing scheme", s)
+	}
+	return u, nil
+}
+
+// IP parses the value as an IPv4 or IPv6 address.
+func (item *Item) IP() (net.IP, error) {
+	s, err := item.value()
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address %q", s)
+	}
+	return ip, nil
+}
+
+// IPNet parses the value as a CIDR network, e.g. "10.0.0.0/8".
+func (item *Item) IPNet() (*net.IPNet, error) {
+	s, err := item.value()
+	if err != nil {
+		return nil, err
+	}
+	_, network, err := net.ParseCIDR(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+// Enum returns the allowed value that matches the value case-insensitively.
+func (item *Item) Enum(allowed ...string) (string, error) {
+	s, err := item.value()
+	if err != nil {
+		return "", err
+	}
+	s = strings.TrimSpace(s)
+	for _, a := range allowed {
+		if strings.EqualFold(s, a) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("invalid value %q, must be one of %s", s, strings.Join(allowed, ", "))
+}
+
+// The Get methods below return the value at a colon- or dot-separated path,
+// see GetValue. They return def if the value is missing or cannot be parsed.
+
+func (cfg *Configuration) GetString(path, def string) string {
+	if s, ok := cfg.GetValue(path); ok {
+		return s
+	}
+	return def
+}
+
+func (cfg *Configuration) GetInt(path string, def int) int {
+	if v, err := cfg.item(path).Int(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetInt64(path string, def int64) int64 {
+	if v, err := cfg.item(path).Int64(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetFloat64(path string, def float64) float64 {
+	if v, err := cfg.item(path).Float64(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetBool(path string, def bool) bool {
+	if v, err := cfg.item(path).Bool(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetDuration(path string, def time.Duration) time.Duration {
+	if v, err := cfg.item(path).Duration(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetSize(path string, def int64) int64 {
+	if v, err := cfg.item(path).Size(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetStrings(path string, def []string) []string {
+	if v, err := cfg.item(path).Strings(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetTime(path string, def time.Time) time.Time {
+	if v, err := cfg.item(path).Time(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetURL(path string, def *url.URL) *url.URL {
+	if v, err := cfg.item(path).URL(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetIP(path string, def net.IP) net.IP {
+	if v, err := cfg.item(path).IP(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetIPNet(path string, def *net.IPNet) *net.IPNet {
+	if v, err := cfg.item(path).IPNet(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (cfg *Configuration) GetEnum(path, def string, allowed ...string) string {
+	if v, err := cfg.item(path).Enum(allowed...); err == nil {
+		return v
+	}
+	return def
+}
+
+// The typed methods of Section below parse the value of key in the section
+// like the Item method of the same name. They return ErrItemNotFound if the
+// section or key is missing.
+
+func (s *Section) Int(key string) (int, error) {
+	return s.Item(key).Int()
+}
+
+func (s *Section) Int64(key string) (int64, error) {
+	return s.Item(key).Int64()
+}
+
+func (s *Section) Float64(key string) (float64, error) {
+	return s.Item(key).Float64()
+}
+
+func (s *Section) Bool(key string) (bool, error) {
+	return s.Item(key).Bool()
+}
+
+func (s *Section) Duration(key string) (time.Duration, error) {
+	return s.Item(key).Duration()
+}
+
+func (s *Section) Size(key string) (int64, error) {
+	return s.Item(key).Size()
+}
+
+func (s *Section) Strings(key string) ([]string, error) {
+	return s.Item(key).Strings()
+}
+
+func (s *Section) Time(key string) (time.Time, error) {
+	return s.Item(key).Time()
+}
+
+func (s *Section) URL(key string) (*url.URL, error) {
+	return s.Item(key).URL()
+}
+
+func (s *Section) IP(key string) (net.IP, error) {
+	return s.Item(key).IP()
+}
+
+func (s *Section) IPNet(key string) (*net.IPNet, error) {
+	return s.Item(key).IPNet()
+}
+
+func (s *Section) Enum(key string, allowed ...string) (string, error) {
+	return s.Item(key).Enum(allowed...)
+}
+
+// The Get methods of Section below return the value of key in the section,
+// or def if the section or key is missing or the value cannot be parsed.
+
+func (s *Section) GetString(key, def string) string {
+	if item := s.Item(key); item != nil {
+		return item.Value
+	}
+	return def
+}
+
+func (s *Section) GetInt(key string, def int) int {
+	if v, err := s.Item(key).Int(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetInt64(key string, def int64) int64 {
+	if v, err := s.Item(key).Int64(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetFloat64(key string, def float64) float64 {
+	if v, err := s.Item(key).Float64(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetBool(key string, def bool) bool {
+	if v, err := s.Item(key).Bool(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetDuration(key string, def time.Duration) time.Duration {
+	if v, err := s.Item(key).Duration(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetSize(key string, def int64) int64 {
+	if v, err := s.Item(key).Size(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetStrings(key string, def []string) []string {
+	if v, err := s.Item(key).Strings(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetTime(key string, def time.Time) time.Time {
+	if v, err := s.Item(key).Time(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetURL(key string, def *url.URL) *url.URL {
+	if v, err := s.Item(key).URL(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetIP(key string, def net.IP) net.IP {
+	if v, err := s.Item(key).IP(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetIPNet(key string, def *net.IPNet) *net.IPNet {
+	if v, err := s.Item(key).IPNet(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (s *Section) GetEnum(key, def string, allowed ...string) string {
+	if v, err := s.Item(key).Enum(allowed...); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,120 @@
+package hosting
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedAccessors(t *testing.T) {
+	cfg, err := NewConfigurationFromJson([]byte(`{
+		"Name": "orders",
+		"Http": {"Timeout": "30s", "MaxBody": "64MB", "Base": "https://example.com/api", "Mode": "Release"},
+		"Cache": {"Size": "1.5k", "Hosts": "a, b,,c", "Bad": "10 parsecs"},
+		"Net": {"Bind": "::1", "Allow": "10.0.0.0/8", "Since": "2018-03-01"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.GetString("Name", "x"); v != "orders" {
+		t.Errorf("Name = %q", v)
+	}
+	if v := cfg.GetDuration("Http:Timeout", time.Second); v != 30*time.Second {
+		t.Errorf("Http:Timeout = %v", v)
+	}
+	if v := cfg.GetDuration("Http:Missing", 5*time.Second); v != 5*time.Second {
+		t.Errorf("Http:Missing = %v", v)
+	}
+	if v := cfg.GetSize("Http.MaxBody", 0); v != 64<<20 {
+		t.Errorf("Http:MaxBody = %d", v)
+	}
+	if v := cfg.GetSize("Cache:Size", 0); v != 1536 {
+		t.Errorf("Cache:Size = %d", v)
+	}
+	if v := cfg.GetSize("Cache:Bad", 7); v != 7 {
+		t.Errorf("Cache:Bad = %d", v)
+	}
+	if v := cfg.GetStrings("Cache:Hosts", nil); !reflect.DeepEqual(v, []string{"a", "b", "c"}) {
+		t.Errorf("Cache:Hosts = %v", v)
+	}
+	if v := cfg.GetURL("Http:Base", nil); v == nil || v.Host != "example.com" {
+		t.Errorf("Http:Base = %v", v)
+	}
+	if v := cfg.GetURL("Name", nil); v != nil {
+		t.Errorf("relative url accepted: %v", v)
+	}
+	if v := cfg.GetEnum("Http:Mode", "debug", "debug", "release"); v != "release" {
+		t.Errorf("Http:Mode = %q", v)
+	}
+	if v := cfg.GetEnum("Name", "debug", "debug", "release"); v != "debug" {
+		t.Errorf("Name as enum = %q", v)
+	}
+	if v := cfg.GetIP("Net:Bind", nil); !v.Equal(net.IPv6loopback) {
+		t.Errorf("Net:Bind = %v", v)
+	}
+	if v := cfg.GetIPNet("Net:Allow", nil); v == nil || !v.Contains(net.ParseIP("10.1.2.3")) {
+		t.Errorf("Net:Allow = %v", v)
+	}
+	if v := cfg.GetTime("Net:Since", time.Time{}); !v.Equal(time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Net:Since = %v", v)
+	}
+
+	if _, err := cfg.Section("Missing").Item("Key").Duration(); err != ErrItemNotFound {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+	if _, err := cfg.Section("Http").Item("Mode").Enum("debug"); err == nil {
+		t.Error("expected enum error")
+	}
+	var nilCfg *Configuration
+	if v := nilCfg.GetInt("A:B", 3); v != 3 {
+		t.Errorf("nil configuration = %d", v)
+	}
+}
+
+func TestSectionAccessors(t *testing.T) {
+	cfg, err := NewConfigurationFromJson([]byte(`{
+		"Http": {"Timeout": "30s", "MaxBody": "64MB", "Port": "8080", "Mode": "Release", "Hosts": "a,b"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	http := cfg.Section("Http")
+	if v, err := http.Duration("Timeout"); err != nil || v != 30*time.Second {
+		t.Errorf("Timeout = %v, %v", v, err)
+	}
+	if v, err := http.Size("MaxBody"); err != nil || v != 64<<20 {
+		t.Errorf("MaxBody = %d, %v", v, err)
+	}
+	if v, err := http.Enum("Mode", "debug", "release"); err != nil || v != "release" {
+		t.Errorf("Mode = %q, %v", v, err)
+	}
+	if _, err := http.Int("Missing"); err != ErrItemNotFound {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+	if v := http.GetInt("Port", 80); v != 8080 {
+		t.Errorf("Port = %d", v)
+	}
+	if v := http.GetString("Missing", "x"); v != "x" {
+		t.Errorf("Missing = %q", v)
+	}
+	if v := http.GetStrings("Hosts", nil); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("Hosts = %v", v)
+	}
+	if v := cfg.Section("Missing").GetDuration("Timeout", time.Second); v != time.Second {
+		t.Errorf("missing section = %v", v)
+	}
+}
+
+func TestSizeOverflow(t *testing.T) {
+	for _, s := range []string{"99999999999TB", "8388608TB", "8388608.5TB", "99999999999999999999", "99999999999999999999.5"} {
+		if v, err := (&Item{Value: s}).Size(); err == nil {
+			t.Errorf("Size(%q) = %d, want an error", s, v)
+		}
+	}
+	if v, err := (&Item{Value: "8388607TB"}).Size(); err != nil || v != 8388607<<40 {
+		t.Errorf("Size(8388607TB) = %d, %v", v, err)
+	}
+}
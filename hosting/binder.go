@@ -0,0 +1,348 @@
+package hosting
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// BindError lists every required key that was missing and every value that
+// could not be converted, by key path.
+type BindError struct {
+	Missing []string
+	Invalid []string
+}
+
+func (e *BindError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing required keys: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid values: "+strings.Join(e.Invalid, "; "))
+	}
+	return "configuration bind error: " + strings.Join(parts, "; ")
+}
+
+// Bind sets the fields of the struct v points to from tree, a nested map
+// with string leaves. Fields match keys case-insensitively by name, or by
+// the name in a `config:"name"` tag; `config:"-"` skips a field and
+// `config:"name,required"` reports the key when it is absent. Fields without
+// a config tag use the name of a json tag, and `json:"-"` skips them too. A
+// `default` tag supplies the value of an absent key.
+//
+// Besides strings, booleans and numbers, fields may be time.Duration
+// ("30s"), time.Time (RFC 3339 or 2006-01-02), encoding.TextUnmarshaler,
+// nested structs, pointers, maps with string keys and slices. Slices bind
+// from indexed children (Hosts:0, Hosts:1) or a comma-separated value.
+// A value of "@" followed by a number binds the number as a string, as the
+// flat configuration always did.
+func Bind(tree map[string]interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("configuration bind error: non-nil pointer required, got %T", v)
+	}
+
+	b := &binder{}
+	b.bind(tree, rv.Elem(), "")
+	if len(b.err.Missing) > 0 || len(b.err.Invalid) > 0 {
+		return &b.err
+	}
+	return nil
+}
+
+type binder struct {
+	err BindError
+}
+
+func (b *binder) invalid(path string, err error) {
+	b.err.Invalid = append(b.err.Invalid, path+": "+err.Error())
+}
+
+func (b *binder) bind(node interface{}, v reflect.Value, path string) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		b.bind(node, v.Elem(), path)
+		return
+	}
+
+	if s, ok := node.(string); ok {
+		if err := setString(v, s); err != nil {
+			b.invalid(path, err)
+		}
+		return
+	}
+
+	m, _ := node.(map[string]interface{})
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() != timeType && !v.Addr().Type().Implements(textUnmarshalerType) {
+			b.bindStruct(m, v, path)
+			return
+		}
+	case reflect.Map:
+		b.bindMap(m, v, path)
+		return
+	case reflect.Slice:
+		b.bindSlice(m, v, path)
+		return
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(node))
+			return
+		}
+	}
+	b.invalid(path, fmt.Errorf("cannot bind a section to %s", v.Type()))
+}
+
+func (b *binder) bindStruct(m map[string]interface{}, v reflect.Value, path string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, required := field.Name, false
+		if tag, ok := field.Tag.Lookup("config"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "required" {
+					required = true
+				}
+			}
+		} else if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if j := strings.Index(tag, ","); j >= 0 {
+				tag = tag[:j]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fv := v.Field(i)
+		if field.Anonymous && name == field.Name {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Ptr && fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(ft))
+				}
+				b.bindStruct(m, reflect.Indirect(fv), path)
+				continue
+			}
+		}
+		if !fv.CanSet() {
+			continue
+		}
+
+		fieldPath := joinPath(path, name)
+		node, ok := lookup(m, name)
+		if !ok {
+			if def, ok := field.Tag.Lookup("default"); ok {
+				b.bind(def, fv, fieldPath)
+			} else if required {
+				b.err.Missing = append(b.err.Missing, fieldPath)
+			}
+			continue
+		}
+		b.bind(node, fv, fieldPath)
+	}
+}
+
+func (b *binder) bindMap(m map[string]interface{}, v reflect.Value, path string) {
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		b.invalid(path, fmt.Errorf("map key must be a string, got %s", t.Key()))
+		return
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	for _, k := range sortedKeys(m) {
+		ev := reflect.New(t.Elem()).Elem()
+		b.bind(m[k], ev, joinPath(path, k))
+		v.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), ev)
+	}
+}
+
+func (b *binder) bindSlice(m map[string]interface{}, v reflect.Value, path string) {
+	keys := make(map[int]string, len(m))
+	indexes := make([]int, 0, len(m))
+	for k := range m {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 {
+			b.invalid(joinPath(path, k), fmt.Errorf("not an array index"))
+			return
+		}
+		keys[i] = k
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	// indexes must run from 0 without gaps, so that a single large index
+	// cannot allocate a huge slice
+	for want, i := range indexes {
+		if i != want {
+			b.invalid(joinPath(path, keys[i]), fmt.Errorf("array index out of sequence, expected %d", want))
+			return
+		}
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(indexes), len(indexes))
+	for _, i := range indexes {
+		b.bind(m[keys[i]], slice.Index(i), joinPath(path, keys[i]))
+	}
+	v.Set(slice)
+}
+
+// setString converts s to the type of v.
+func setString(v reflect.Value, s string) error {
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if strings.HasPrefix(s, "@") && isNumber(s[1:]) {
+			s = s[1:]
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		x, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.Slice:
+		parts := splitList(s)
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setString(slice.Index(i), part); err != nil {
+				return fmt.Errorf("element %d: %s", i, err.Error())
+			}
+		}
+		v.Set(slice)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("cannot bind a value to %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("cannot bind a value to %s", v.Type())
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// splitList splits a comma-separated value, trimming spaces and dropping
+// empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && s != "" && !strings.ContainsAny(s, "eEnNiI")
+}
+
+func lookup(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + keyDelimiter + key
+}
@@ -1,11 +1,8 @@
 package hosting
 
 import (
-	"bytes"
-	"encoding/json"
-	"regexp"
+	"fmt"
 	"strconv"
-
 	"strings"
 
 	"github.com/go-ini/ini"
@@ -15,7 +12,11 @@ type Configuration struct {
 	Sections []*Section
 }
 
+// Section returns the named section, or nil.
 func (cfg *Configuration) Section(name string) *Section {
+	if cfg == nil {
+		return nil
+	}
 	for _, section := range cfg.Sections {
 		if section.Name == name {
 			return section
@@ -24,6 +25,41 @@ func (cfg *Configuration) Section(name string) *Section {
 	return nil
 }
 
+// set stores value under section and key, adding the section or item when
+// it does not exist yet.
+func (cfg *Configuration) set(section, key, value string) {
+	s := cfg.Section(section)
+	if s == nil {
+		s = &Section{Name: section}
+		cfg.Sections = append(cfg.Sections, s)
+	}
+	if item := s.Item(key); item != nil {
+		item.Value = value
+		return
+	}
+	s.Items = append(s.Items, &Item{Key: key, Value: value})
+}
+
+// setPath stores value under a key path; a top-level scalar belongs to
+// the default section and an empty path is ignored.
+func (cfg *Configuration) setPath(path []string, value string) {
+	if len(path) == 0 {
+		return
+	}
+	section, key := splitKeyPath(path)
+	cfg.set(section, key, value)
+}
+
+// splitKeyPath splits a key path into a section and a key. Leading parts
+// name the section, joined the way go-ini names child sections; a single
+// part belongs to the default section.
+func splitKeyPath(parts []string) (section, key string) {
+	if len(parts) == 1 {
+		return ini.DefaultSection, parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], "."), parts[len(parts)-1]
+}
+
 func (cfg *Configuration) Remove(name string) {
 	len := len(cfg.Sections)
 	for i, section := range cfg.Sections {
@@ -37,53 +73,133 @@ func (cfg *Configuration) Remove(name string) {
 	}
 }
 
+// Object binds the configuration to the struct v points to. See Bind for
+// the supported tags and field types.
 func (cfg *Configuration) Object(v interface{}) error {
-	var buffer bytes.Buffer
-	buffer.WriteString("{")
-	for i, section := range cfg.Sections {
-		if i > 0 {
-			buffer.WriteString(",")
-		}
-		buffer.WriteString("\"")
-		buffer.WriteString(section.Name)
-		buffer.WriteString("\":{")
-		for j, item := range section.Items {
-			if j > 0 {
-				buffer.WriteString(",")
+	tree, err := cfg.tree()
+	if err != nil {
+		return err
+	}
+	return Bind(tree, v)
+}
+
+// GetSection returns the section at a colon- or dot-separated path, so
+// "Database:Replicas:0" finds the section named "Database.Replicas.0".
+func (cfg *Configuration) GetSection(path string) *Section {
+	return cfg.Section(strings.Join(splitPath(path), "."))
+}
+
+// GetValue returns the value at a colon- or dot-separated path. The last
+// part is the key, the rest the section; a single part is looked up in the
+// default section. Keys that contain dots are found as well.
+func (cfg *Configuration) GetValue(path string) (string, bool) {
+	if item := cfg.item(path); item != nil {
+		return item.Value, true
+	}
+	return "", false
+}
+
+func (cfg *Configuration) item(path string) *Item {
+	parts := splitPath(path)
+	if len(parts) == 0 {
+		return nil
+	}
+	for i := len(parts) - 1; i >= 1; i-- {
+		if section := cfg.Section(strings.Join(parts[:i], ".")); section != nil {
+			if item := section.Item(strings.Join(parts[i:], ".")); item != nil {
+				return item
 			}
-			buffer.WriteString("\"")
-			buffer.WriteString(item.Key)
-			buffer.WriteString("\":")
-			const (
-				numPattern = "^(-|\\+)?\\d+(\\.\\d+)?$"
-			)
-			if strings.HasPrefix(item.Value, "@") {
-				buffer.WriteString("\"")
-				sNum := item.Value[1:]
-				if ok, _ := regexp.MatchString(numPattern, sNum); ok {
-					buffer.WriteString(sNum)
-				} else {
-					buffer.WriteString(item.Value)
+		}
+	}
+	if section := cfg.Section(ini.DefaultSection); section != nil {
+		return section.Item(strings.Join(parts, "."))
+	}
+	return nil
+}
+
+// ChildSections returns the direct children of the section at path, in the
+// order they first appear. An empty path returns the top-level sections.
+// A child that only exists through its own children, like Database.Replicas
+// for Database.Replicas.0, is returned as an empty section.
+func (cfg *Configuration) ChildSections(path string) []*Section {
+	prefix := strings.Join(splitPath(path), ".")
+	if prefix != "" {
+		prefix += "."
+	}
+
+	var children []*Section
+	seen := make(map[string]bool)
+	for _, section := range cfg.Sections {
+		if section.Name == ini.DefaultSection || !strings.HasPrefix(section.Name, prefix) {
+			continue
+		}
+		name := section.Name
+		if i := strings.Index(name[len(prefix):], "."); i >= 0 {
+			name = name[:len(prefix)+i]
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if child := cfg.Section(name); child != nil {
+			children = append(children, child)
+		} else {
+			children = append(children, &Section{Name: name})
+		}
+	}
+	return children
+}
+
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == ':' || r == '.'
+	})
+}
+
+// tree nests the sections by their dotted names, with item values as
+// string leaves. Items of the default section also appear at the top level.
+// A key that is both a value and a section, like item Db next to section
+// Db.Primary, is an error.
+func (cfg *Configuration) tree() (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	for _, section := range cfg.Sections {
+		node := root
+		parts := strings.Split(section.Name, ".")
+		for i, part := range parts {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				if _, isValue := node[part]; isValue {
+					return nil, keyConflict(parts[:i+1])
 				}
-				buffer.WriteString("\"")
-			} else {
-				if ok, _ := regexp.MatchString(numPattern, item.Value); ok {
-					buffer.WriteString(item.Value)
-				} else if item.Value == "true" || item.Value == "false" {
-					buffer.WriteString(item.Value)
-				} else {
-					buffer.WriteString("\"")
-					buffer.WriteString(item.Value)
-					buffer.WriteString("\"")
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		for _, item := range section.Items {
+			if err := setLeaf(node, item.Key, item.Value, append(parts, item.Key)); err != nil {
+				return nil, err
+			}
+			if section.Name == ini.DefaultSection {
+				if err := setLeaf(root, item.Key, item.Value, []string{item.Key}); err != nil {
+					return nil, err
 				}
 			}
-			j++
 		}
-		buffer.WriteString("}")
 	}
-	buffer.WriteString("}")
-	err := json.Unmarshal(buffer.Bytes(), v)
-	return err
+	return root, nil
+}
+
+func setLeaf(node map[string]interface{}, key, value string, path []string) error {
+	if _, ok := node[key].(map[string]interface{}); ok {
+		return keyConflict(path)
+	}
+	node[key] = value
+	return nil
+}
+
+func keyConflict(path []string) error {
+	return fmt.Errorf("configuration key %s is both a value and a section", strings.Join(path, ":"))
 }
 
 type Section struct {
@@ -92,7 +208,12 @@ type Section struct {
 	Items []*Item
 }
 
+// Item returns the item with key, or nil. It is safe to call on a nil
+// section, and the accessors of a nil item return ErrItemNotFound.
 func (s *Section) Item(key string) *Item {
+	if s == nil {
+		return nil
+	}
 	for _, item := range s.Items {
 		if item.Key == key {
 			return item
@@ -114,25 +235,17 @@ func (s *Section) Remove(key string) {
 	}
 }
 
+// Merge returns a new section with the items of s followed by the items
+// only other has; other's values win. Neither section is modified. Sections
+// with different names are not merged and other is returned.
 func (s *Section) Merge(other *Section) *Section {
-	if s.Name == other.Name {
-		target := &Section{
-			Level: 0,
-			Name:  s.Name,
-		}
-		section := make(map[string]*Item)
-		for _, v := range s.Items {
-			section[v.Key] = v
-		}
-		for _, v := range other.Items {
-			section[v.Key] = v
-		}
-		for _, item := range section {
-			target.Items = append(target.Items, item)
-		}
-		return target
+	if s.Name != other.Name {
+		return other
 	}
-	return other
+	return mergeConfigurations([]*Configuration{
+		NewConfiguration([]*Section{s}),
+		NewConfiguration([]*Section{other}),
+	}).Sections[0]
 }
 
 type Item struct {
@@ -141,11 +254,16 @@ type Item struct {
 }
 
 func (item *Item) String() string {
-	return item.Value
+	s, _ := item.value()
+	return s
 }
 
 func (item *Item) Int() (int, error) {
-	val, err := strconv.ParseInt(item.Value, 10, 32)
+	s, err := item.value()
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(s, 10, 32)
 	if err != nil {
 		return 0, err
 	}
@@ -158,7 +276,11 @@ func (item *Item) MustInt() int {
 }
 
 func (item *Item) Int64() (int64, error) {
-	val, err := strconv.ParseInt(item.Value, 10, 64)
+	s, err := item.value()
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
 		return 0, err
 	}
@@ -171,7 +293,11 @@ func (item *Item) MustInt64() int64 {
 }
 
 func (item *Item) Float32() (float32, error) {
-	val, err := strconv.ParseFloat(item.Value, 32)
+	s, err := item.value()
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseFloat(s, 32)
 	if err != nil {
 		return 0, err
 	}
@@ -184,7 +310,11 @@ func (item *Item) MustFloat32() float32 {
 }
 
 func (item *Item) Float64() (float64, error) {
-	val, err := strconv.ParseFloat(item.Value, 64)
+	s, err := item.value()
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
 		return 0, err
 	}
@@ -197,7 +327,11 @@ func (item *Item) MustFloat64() float64 {
 }
 
 func (item *Item) Bool() (bool, error) {
-	val, err := strconv.ParseBool(item.Value)
+	s, err := item.value()
+	if err != nil {
+		return false, err
+	}
+	val, err := strconv.ParseBool(s)
 	if err != nil {
 		return false, err
 	}
@@ -2,68 +2,291 @@ package hosting
 
 import (
 	"errors"
-	"log"
+	"io/ioutil"
+	"os"
 	"path"
-	"sort"
+	"strings"
+	"sync"
 
 	"github.com/go-ini/ini"
 )
 
+const (
+	keyDelimiter    = ":"
+	envKeyDelimiter = "__"
+)
+
 type ConfigurationBuilder struct {
-	Config  *Configuration
-	baseDir string
-	sources []*Configuration
+	Config      *Configuration
+	baseDir     string
+	environment string
+	interpolate bool
+	mu          sync.Mutex // guards Config, sources and errs
+	sources     []*configurationSource
+	errs        []error
+}
+
+// configurationSource is a source added to a builder. File sources keep
+// their path and parser so they can be read again; config is nil while an
+// optional file is missing.
+type configurationSource struct {
+	config   *Configuration
+	path     string
+	parse    func([]byte) (*Configuration, error)
+	optional bool
+}
+
+// read loads the file, returning nil for a missing optional file. Errors
+// are *FileError.
+func (s *configurationSource) read() (*Configuration, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if s.optional && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, &FileError{Path: s.path, Err: err}
+	}
+	c, err := s.parse(data)
+	if err != nil {
+		return nil, newFileError(s.path, data, err)
+	}
+	return c, nil
 }
 
 func (this *ConfigurationBuilder) AddIniFile(fileName string) *ConfigurationBuilder {
-	file, err := ini.Load(this.getFilePath(fileName))
+	return this.addFile(fileName, parseIni, false)
+}
+
+func (this *ConfigurationBuilder) AddIniFileOptional(fileName string) *ConfigurationBuilder {
+	return this.addFile(fileName, parseIni, true)
+}
+
+func (this *ConfigurationBuilder) AddJsonFile(fileName string) *ConfigurationBuilder {
+	return this.addFile(fileName, NewConfigurationFromJson, false)
+}
+
+func (this *ConfigurationBuilder) AddJsonFileOptional(fileName string) *ConfigurationBuilder {
+	return this.addFile(fileName, NewConfigurationFromJson, true)
+}
+
+func (this *ConfigurationBuilder) AddYamlFile(fileName string) *ConfigurationBuilder {
+	return this.addFile(fileName, NewConfigurationFromYaml, false)
+}
+
+func (this *ConfigurationBuilder) AddYamlFileOptional(fileName string) *ConfigurationBuilder {
+	return this.addFile(fileName, NewConfigurationFromYaml, true)
+}
+
+func (this *ConfigurationBuilder) addFile(fileName string, parse func([]byte) (*Configuration, error), optional bool) *ConfigurationBuilder {
+	source := &configurationSource{
+		path:     this.getFilePath(fileName),
+		parse:    parse,
+		optional: optional,
+	}
+	c, err := source.read()
+	source.config = c
+
+	this.mu.Lock()
+	this.sources = append(this.sources, source)
 	if err != nil {
-		strError := "configuration file load error：" + err.Error()
-		log.Fatal(strError)
-		panic(strError)
+		this.errs = append(this.errs, err)
 	}
-	this.sources = append(this.sources, NewConfigurationFromFile(file))
+	this.mu.Unlock()
 	return this
 }
 
-func (this *ConfigurationBuilder) AddIniFileOptional(fileName string) *ConfigurationBuilder {
-	file, err := ini.Load(this.getFilePath(fileName))
-	if err == nil {
-		this.sources = append(this.sources, NewConfigurationFromFile(file))
+func (this *ConfigurationBuilder) addError(err error) {
+	this.mu.Lock()
+	this.errs = append(this.errs, err)
+	this.mu.Unlock()
+}
+
+// AddFileWithEnvironment adds fileName followed by the optional file for the
+// current environment, e.g. appsettings.ini and appsettings.staging.ini.
+// The provider is chosen by extension: .ini, .json, .yaml or .yml.
+func (this *ConfigurationBuilder) AddFileWithEnvironment(fileName string) *ConfigurationBuilder {
+	parse, err := fileProvider(fileName)
+	if err != nil {
+		this.addError(&FileError{Path: this.getFilePath(fileName), Err: err})
+		return this
+	}
+	this.addFile(fileName, parse, false)
+
+	ext := path.Ext(fileName)
+	base := strings.TrimSuffix(fileName, ext)
+	env := this.Environment()
+	envFile := base + "." + env + ext
+	if _, err := os.Stat(this.getFilePath(envFile)); err != nil && env != strings.ToLower(env) {
+		envFile = base + "." + strings.ToLower(env) + ext
 	}
+	return this.addFile(envFile, parse, true)
+}
+
+// SetEnvironment overrides EnvironmentName for this builder.
+func (this *ConfigurationBuilder) SetEnvironment(name string) *ConfigurationBuilder {
+	this.environment = name
 	return this
 }
 
-func (this *ConfigurationBuilder) Build() (*Configuration, error) {
-	l := len(this.sources)
-	if l <= 0 {
-		return nil, errors.New("none config file to build")
+// EnableInterpolation makes Build resolve references such as ${Section:Key}
+// and ${env:NAME} in the merged values, see InterpolationError. Values are
+// left untouched otherwise.
+func (this *ConfigurationBuilder) EnableInterpolation() *ConfigurationBuilder {
+	this.interpolate = true
+	return this
+}
+
+func (this *ConfigurationBuilder) Environment() string {
+	if this.environment != "" {
+		return this.environment
 	}
-	var allSections SectionPointerSlice
-	for i, c := range this.sources {
-		for _, section := range c.Sections {
-			section.Level = l - i
-			allSections = append(allSections, section)
+	return EnvironmentName
+}
+
+func (this *ConfigurationBuilder) AddEnvironmentVariables(prefix string) *ConfigurationBuilder {
+	return this.AddEnvironmentVariablesFrom(prefix, os.Environ())
+}
+
+// AddEnvironmentVariablesFrom adds KEY=VALUE pairs whose key starts with prefix.
+// The prefix is stripped and "__" separates the section from the key, so
+// APP_Database__Host sets Host in section Database.
+func (this *ConfigurationBuilder) AddEnvironmentVariablesFrom(prefix string, environ []string) *ConfigurationBuilder {
+	c := NewConfiguration(nil)
+	for _, env := range environ {
+		i := strings.Index(env, "=")
+		if i <= 0 || len(env[:i]) <= len(prefix) || !strings.EqualFold(env[:len(prefix)], prefix) {
+			continue
 		}
+		section, key := splitKeyPath(strings.Split(env[len(prefix):i], envKeyDelimiter))
+		c.set(section, key, env[i+1:])
 	}
-	sort.Sort(allSections)
-	targetSectionsMap := make(map[string]*Section)
-	for i, section := range allSections {
-		if i == 0 {
-			targetSectionsMap[section.Name] = section
+	this.addSource(c)
+	return this
+}
+
+// AddCommandLine adds --Section:Key=value and --Section:Key value arguments.
+// Arguments that are not of this form are ignored.
+func (this *ConfigurationBuilder) AddCommandLine(args []string) *ConfigurationBuilder {
+	c := NewConfiguration(nil)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			continue
+		}
+		arg = arg[2:]
+
+		var name, value string
+		if j := strings.Index(arg, "="); j >= 0 {
+			name, value = arg[:j], arg[j+1:]
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			name, value = arg, args[i+1]
+			i++
 		} else {
-			mergeSection := allSections[i-1].Merge(section)
-			targetSectionsMap[mergeSection.Name] = mergeSection
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		section, key := splitKeyPath(strings.Split(name, keyDelimiter))
+		c.set(section, key, value)
+	}
+	this.addSource(c)
+	return this
+}
+
+// Build merges the sources in the order they were added; a later source
+// overrides the values of an earlier one. Sections and items keep the order
+// in which they first appear, and the sources are left untouched, so Build
+// can be called again or concurrently.
+//
+// If any source failed to load, Build returns a MultiError holding a
+// *FileError for each of them. With EnableInterpolation, references in the
+// merged values are then resolved, see InterpolationError.
+func (this *ConfigurationBuilder) Build() (*Configuration, error) {
+	sources, err := this.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := mergeSources(sources)
+	if this.interpolate {
+		if err := interpolate(cfg); err != nil {
+			return nil, err
+		}
+	}
+	this.mu.Lock()
+	this.Config = cfg
+	this.mu.Unlock()
+	return cfg, nil
+}
+
+// MustBuild is like Build but panics if the configuration cannot be built.
+func (this *ConfigurationBuilder) MustBuild() *Configuration {
+	cfg, err := this.Build()
+	if err != nil {
+		panic("configuration build error：" + err.Error())
+	}
+	return cfg
+}
+
+// snapshot returns copies of the sources, so that reloading them does not
+// touch the builder, or the errors collected while adding them.
+func (this *ConfigurationBuilder) snapshot() ([]configurationSource, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if len(this.errs) > 0 {
+		errs := make(MultiError, len(this.errs))
+		copy(errs, this.errs)
+		return nil, errs
+	}
+	if len(this.sources) <= 0 {
+		return nil, errors.New("none config file to build")
+	}
+
+	sources := make([]configurationSource, len(this.sources))
+	for i, source := range this.sources {
+		sources[i] = *source
+	}
+	return sources, nil
+}
+
+func mergeSources(sources []configurationSource) *Configuration {
+	configs := make([]*Configuration, 0, len(sources))
+	for _, source := range sources {
+		if source.config != nil {
+			configs = append(configs, source.config)
 		}
 	}
-	targetSections := make([]*Section, len(targetSectionsMap))
-	i := 0
-	for _, section := range targetSectionsMap {
-		targetSections[i] = section
-		i++
+	return mergeConfigurations(configs)
+}
+
+func mergeConfigurations(sources []*Configuration) *Configuration {
+	var targetSections []*Section
+	sectionIndex := make(map[string]*Section)
+	itemIndex := make(map[*Section]map[string]*Item)
+	for _, c := range sources {
+		for _, section := range c.Sections {
+			target, ok := sectionIndex[strings.ToLower(section.Name)]
+			if !ok {
+				target = &Section{Name: section.Name}
+				sectionIndex[strings.ToLower(section.Name)] = target
+				itemIndex[target] = make(map[string]*Item)
+				targetSections = append(targetSections, target)
+			}
+			items := itemIndex[target]
+			for _, item := range section.Items {
+				if existing, ok := items[strings.ToLower(item.Key)]; ok {
+					existing.Value = item.Value
+					continue
+				}
+				copied := &Item{Key: item.Key, Value: item.Value}
+				items[strings.ToLower(item.Key)] = copied
+				target.Items = append(target.Items, copied)
+			}
+		}
 	}
-	this.Config = NewConfiguration(targetSections)
-	return this.Config, nil
+	return NewConfiguration(targetSections)
 }
 
 func (this *ConfigurationBuilder) BuildToObject(v interface{}) error {
@@ -81,10 +304,36 @@ func (this *ConfigurationBuilder) SetBasePath(path string) *ConfigurationBuilder
 
 func NewConfigurationBuilder() *ConfigurationBuilder {
 	builder := new(ConfigurationBuilder)
-	builder.sources = make([]*Configuration, 0, 3)
+	builder.sources = make([]*configurationSource, 0, 3)
 	return builder
 }
 
+func fileProvider(fileName string) (func([]byte) (*Configuration, error), error) {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".ini":
+		return parseIni, nil
+	case ".json":
+		return NewConfigurationFromJson, nil
+	case ".yaml", ".yml":
+		return NewConfigurationFromYaml, nil
+	}
+	return nil, errors.New("unsupported configuration file type: " + fileName)
+}
+
+func parseIni(data []byte) (*Configuration, error) {
+	file, err := ini.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewConfigurationFromFile(file), nil
+}
+
+func (this *ConfigurationBuilder) addSource(c *Configuration) {
+	this.mu.Lock()
+	this.sources = append(this.sources, &configurationSource{config: c})
+	this.mu.Unlock()
+}
+
 func (this *ConfigurationBuilder) getFilePath(fileName string) string {
 	return path.Join(this.baseDir, fileName)
 }
@@ -0,0 +1,227 @@
+package hosting
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, data string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "hosting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestEnvironmentAndCommandLine(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "app.ini", "[Database]\nHost = ini\nPort = 3306\nUser = app\n")
+
+	cfg, err := NewConfigurationBuilder().
+		SetBasePath(dir).
+		AddIniFile("app.ini").
+		AddEnvironmentVariablesFrom("APP_", []string{"APP_Database__Host=env", "app_Database__Port=3307", "OTHER_Database__User=x"}).
+		AddCommandLine([]string{"serve", "--Database:Host=cli", "--Name", "orders"}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := cfg.Section("Database")
+	if db.Item("Host").Value != "cli" || db.Item("Port").Value != "3307" || db.Item("User").Value != "app" {
+		t.Errorf("unexpected Database section: %v %v %v", db.Item("Host"), db.Item("Port"), db.Item("User"))
+	}
+	if item := cfg.Section("DEFAULT").Item("Name"); item == nil || item.Value != "orders" {
+		t.Errorf("unexpected Name: %v", item)
+	}
+}
+
+func TestEnvironmentOverridesIgnoreCase(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "app.ini", "[Database]\nHost = ini\n\n[Logging.LogLevel]\nDefault = Information\n")
+
+	cfg, err := NewConfigurationBuilder().
+		SetBasePath(dir).
+		AddIniFile("app.ini").
+		AddEnvironmentVariablesFrom("APP_", []string{"APP_DATABASE__HOST=env", "APP_LOGGING__LOGLEVEL__DEFAULT=Debug"}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.Sections) != 3 {
+		t.Errorf("sections were not merged: %v", cfg.Sections)
+	}
+	if host := cfg.GetString("Database:Host", ""); host != "env" {
+		t.Errorf("Database:Host = %q, want env", host)
+	}
+	var v struct {
+		Database struct{ Host string }
+	}
+	if err := cfg.Object(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Database.Host != "env" {
+		t.Errorf("Object Database.Host = %q, want env", v.Database.Host)
+	}
+	if level := cfg.GetString("Logging:LogLevel:Default", ""); level != "Debug" {
+		t.Errorf("Logging:LogLevel:Default = %q, want Debug", level)
+	}
+}
+
+func TestJsonAndYamlFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "app.ini", "[Logging.LogLevel]\nDefault = Information\nSystem = Warning\n")
+	writeFile(t, dir, "appsettings.json", `{
+		"AllowedHosts": "*",
+		"Logging": {"LogLevel": {"Default": "Warning"}},
+		"Hosts": ["a", "b"],
+		"Retries": 3
+	}`)
+	writeFile(t, dir, "app.yaml", "Logging:\n  LogLevel:\n    Microsoft: Error\nDebug: true\n")
+
+	cfg, err := NewConfigurationBuilder().
+		SetBasePath(dir).
+		AddIniFile("app.ini").
+		AddJsonFile("appsettings.json").
+		AddYamlFile("app.yaml").
+		AddJsonFileOptional("missing.json").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	level := cfg.Section("Logging.LogLevel")
+	if level.Item("Default").Value != "Warning" || level.Item("System").Value != "Warning" || level.Item("Microsoft").Value != "Error" {
+		t.Errorf("unexpected Logging.LogLevel section: %+v", level.Items)
+	}
+	def := cfg.Section("DEFAULT")
+	if def.Item("AllowedHosts").Value != "*" || def.Item("Retries").Value != "3" || def.Item("Debug").Value != "true" {
+		t.Errorf("unexpected DEFAULT section: %+v", def.Items)
+	}
+	if hosts := cfg.Section("Hosts"); hosts.Item("0").Value != "a" || hosts.Item("1").Value != "b" {
+		t.Errorf("unexpected Hosts section: %+v", hosts.Items)
+	}
+}
+
+func TestAddFileWithEnvironment(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "appsettings.ini", "[Http]\nPort = 80\nTimeout = 30s\n")
+	writeFile(t, dir, "appsettings.staging.ini", "[Http]\nPort = 8080\n")
+	writeFile(t, dir, "appsettings.qa.ini", "[Http]\nPort = 9090\n")
+
+	for env, port := range map[string]string{"Staging": "8080", "qa": "9090", "production": "80"} {
+		cfg, err := NewConfigurationBuilder().
+			SetBasePath(dir).
+			SetEnvironment(env).
+			AddFileWithEnvironment("appsettings.ini").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		http := cfg.Section("Http")
+		if http.Item("Port").Value != port || http.Item("Timeout").Value != "30s" {
+			t.Errorf("%s: unexpected Http section: %+v", env, http.Items)
+		}
+	}
+}
+
+func TestBuildOrderedMerge(t *testing.T) {
+	builder := NewConfigurationBuilder().
+		AddCommandLine([]string{"--Db:Host=a", "--Db:Port=1", "--Http:Port=80"}).
+		AddCommandLine([]string{"--Db:User=u", "--Cache:Host=c"}).
+		AddCommandLine([]string{"--Db:Host=b", "--Db:Pool=5"})
+
+	var wg sync.WaitGroup
+	results := make([]*Configuration, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg, err := builder.Build()
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = cfg
+		}(i)
+	}
+	wg.Wait()
+
+	for _, cfg := range results {
+		var sections, items []string
+		for _, section := range cfg.Sections {
+			sections = append(sections, section.Name)
+		}
+		for _, item := range cfg.Section("Db").Items {
+			items = append(items, item.Key+"="+item.Value)
+		}
+		if !reflect.DeepEqual(sections, []string{"Db", "Http", "Cache"}) ||
+			!reflect.DeepEqual(items, []string{"Host=b", "Port=1", "User=u", "Pool=5"}) {
+			t.Fatalf("unexpected result: %v %v", sections, items)
+		}
+	}
+
+	if v := builder.sources[0].config.Section("Db").Item("Host").Value; v != "a" {
+		t.Errorf("source was modified: Host = %s", v)
+	}
+}
+
+func TestBuildCollectsErrors(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "bad.ini", "[Database]\nHost = a\n[Cache\nSize = 1\n")
+	writeFile(t, dir, "bad.json", "{\n  \"A\": 1,\n  \"B\" 2\n}")
+
+	builder := NewConfigurationBuilder().
+		SetBasePath(dir).
+		AddIniFile("missing.ini").
+		AddIniFile("bad.ini").
+		AddJsonFileOptional("bad.json").
+		AddJsonFileOptional("missing.json").
+		AddFileWithEnvironment("app.txt")
+	_, err := builder.Build()
+	errs, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %d: %v", len(errs), errs)
+	}
+
+	missing := errs[0].(*FileError)
+	if missing.Path != filepath.Join(dir, "missing.ini") || !os.IsNotExist(missing.Err) {
+		t.Errorf("missing file error = %v", missing)
+	}
+	bad := errs[1].(*FileError)
+	if bad.Line != 3 || bad.Context != "[Cache" {
+		t.Errorf("ini error = %+v", bad)
+	}
+	if want := filepath.Join(dir, "bad.ini") + ":3: "; !strings.HasPrefix(bad.Error(), want) {
+		t.Errorf("ini error message = %q", bad.Error())
+	}
+	if line := errs[2].(*FileError).Line; line != 3 {
+		t.Errorf("json error line = %d", line)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustBuild to panic")
+		}
+	}()
+	builder.MustBuild()
+}
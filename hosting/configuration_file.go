@@ -0,0 +1,101 @@
+package hosting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NewConfigurationFromJson flattens a JSON document into sections. Leaf
+// values are addressed by their path: the last element is the item key and
+// the rest, joined with ".", the section. Array elements use their index.
+func NewConfigurationFromJson(data []byte) (*Configuration, error) {
+	c := NewConfiguration(nil)
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := flattenJson(d, nil, c); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after top-level value at offset %d", d.InputOffset())
+	}
+	return c, nil
+}
+
+func flattenJson(d *json.Decoder, path []string, c *Configuration) error {
+	token, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			for d.More() {
+				key, err := d.Token()
+				if err != nil {
+					return err
+				}
+				if err := flattenJson(d, appendPath(path, key.(string)), c); err != nil {
+					return err
+				}
+			}
+		case '[':
+			for i := 0; d.More(); i++ {
+				if err := flattenJson(d, appendPath(path, strconv.Itoa(i)), c); err != nil {
+					return err
+				}
+			}
+		}
+		_, err = d.Token()
+		return err
+	case nil:
+		c.setPath(path, "")
+	case string:
+		c.setPath(path, t)
+	default:
+		c.setPath(path, fmt.Sprint(t))
+	}
+	return nil
+}
+
+// NewConfigurationFromYaml flattens a YAML document the same way as
+// NewConfigurationFromJson.
+func NewConfigurationFromYaml(data []byte) (*Configuration, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	c := NewConfiguration(nil)
+	flattenYaml(doc, nil, c)
+	return c, nil
+}
+
+func flattenYaml(v interface{}, path []string, c *Configuration) {
+	switch t := v.(type) {
+	case yaml.MapSlice:
+		for _, item := range t {
+			flattenYaml(item.Value, appendPath(path, fmt.Sprint(item.Key)), c)
+		}
+	case []interface{}:
+		for i, item := range t {
+			flattenYaml(item, appendPath(path, strconv.Itoa(i)), c)
+		}
+	case nil:
+		c.setPath(path, "")
+	default:
+		c.setPath(path, fmt.Sprint(t))
+	}
+}
+
+func appendPath(path []string, key string) []string {
+	p := make([]string, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, key)
+}
@@ -0,0 +1,193 @@
+package hosting
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHierarchicalConfiguration(t *testing.T) {
+	cfg, err := NewConfigurationBuilder().
+		AddCommandLine([]string{
+			"--Database:Name=orders",
+			"--Database:Replicas:0:Host=db1",
+			"--Database:Replicas:0:Port=3306",
+			"--Database:Replicas:1:Host=db2",
+			"--Database:Tags:0=primary",
+			"--Database:Tags:1=say \"hi\"",
+			"--AllowedHosts=*",
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := cfg.GetValue("Database:Replicas:1:Host"); !ok || v != "db2" {
+		t.Errorf("GetValue = %q, %v", v, ok)
+	}
+	if v, ok := cfg.GetValue("Database.Name"); !ok || v != "orders" {
+		t.Errorf("GetValue = %q, %v", v, ok)
+	}
+	if s := cfg.GetSection("Database:Replicas:0"); s == nil || s.Item("Port").Value != "3306" {
+		t.Errorf("GetSection = %+v", s)
+	}
+	children := cfg.ChildSections("Database")
+	names := make(map[string]bool)
+	for _, child := range children {
+		names[child.Name] = true
+	}
+	if len(children) != 2 || !names["Database.Replicas"] || !names["Database.Tags"] {
+		t.Errorf("ChildSections = %+v", children)
+	}
+
+	var v struct {
+		AllowedHosts string
+		Database     struct {
+			Name     string
+			Replicas []struct {
+				Host string
+				Port int
+			}
+			Tags []string
+		}
+	}
+	if err := cfg.Object(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.AllowedHosts != "*" || v.Database.Name != "orders" || len(v.Database.Replicas) != 2 ||
+		v.Database.Replicas[0].Port != 3306 || v.Database.Replicas[1].Host != "db2" ||
+		len(v.Database.Tags) != 2 || v.Database.Tags[1] != `say "hi"` {
+		t.Errorf("unexpected object: %+v", v)
+	}
+}
+
+func TestObjectBinder(t *testing.T) {
+	cfg := NewConfiguration([]*Section{
+		{Name: "DEFAULT", Items: []*Item{{Key: "Name", Value: `C:\apps\"orders"`}}},
+		{Name: "Http", Items: []*Item{
+			{Key: "Timeout", Value: "30s"},
+			{Key: "Code", Value: "007"},
+			{Key: "Ratio", Value: "1e5"},
+			{Key: "Listen", Value: "10.0.0.1"},
+			{Key: "Hosts", Value: "a, b,c"},
+			{Key: "Since", Value: "2018-03-01"},
+			{Key: "Pin", Value: "@123"},
+		}},
+		{Name: "Http.Headers", Items: []*Item{{Key: "X-App", Value: "orders"}}},
+	})
+
+	var v struct {
+		Name string
+		Http struct {
+			Timeout  time.Duration
+			Code     string
+			Ratio    float64
+			Listen   net.IP
+			Hosts    []string
+			Since    time.Time
+			Pin      string
+			Headers  map[string]string
+			Retries  int           `default:"3"`
+			Idle     time.Duration `config:"IdleTimeout" default:"1m"`
+			Internal string        `config:"-"`
+		}
+	}
+	if err := cfg.Object(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	h := v.Http
+	if v.Name != `C:\apps\"orders"` || h.Timeout != 30*time.Second || h.Code != "007" || h.Ratio != 1e5 ||
+		!h.Listen.Equal(net.ParseIP("10.0.0.1")) || !reflect.DeepEqual(h.Hosts, []string{"a", "b", "c"}) ||
+		h.Since.Year() != 2018 || h.Pin != "123" || h.Headers["X-App"] != "orders" ||
+		h.Retries != 3 || h.Idle != time.Minute {
+		t.Errorf("unexpected object: %+v", v)
+	}
+
+	var tagged struct {
+		Database struct {
+			HostName string `json:"host_name"`
+			Port     int    `json:"port,omitempty"`
+			User     string `json:"-"`
+			Password string `json:"-" config:"pwd"`
+		}
+	}
+	db := NewConfiguration([]*Section{{Name: "Database", Items: []*Item{
+		{Key: "host_name", Value: "db1"}, {Key: "port", Value: "3306"}, {Key: "User", Value: "x"}, {Key: "pwd", Value: "p"},
+	}}})
+	if err := db.Object(&tagged); err != nil {
+		t.Fatal(err)
+	}
+	if d := tagged.Database; d.HostName != "db1" || d.Port != 3306 || d.User != "" || d.Password != "p" {
+		t.Errorf("json tags were not used: %+v", d)
+	}
+
+	var required struct {
+		Database struct {
+			Host string `config:",required"`
+			User string `config:"Username,required"`
+			Port int
+		}
+		Token string `config:"token,required"`
+	}
+	bad := NewConfiguration([]*Section{{Name: "Database", Items: []*Item{{Key: "Port", Value: "x"}}}})
+	err := bad.Object(&required)
+	be, ok := err.(*BindError)
+	if !ok || !reflect.DeepEqual(be.Missing, []string{"Database:Host", "Database:Username", "token"}) || len(be.Invalid) != 1 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBindSliceIndexes(t *testing.T) {
+	for _, args := range [][]string{
+		{"--Hosts:99999999999=x"},
+		{"--Hosts:0=a", "--Hosts:5=b"},
+		{"--Hosts:1=a", "--Hosts:01=b"},
+	} {
+		cfg, err := NewConfigurationBuilder().AddCommandLine(args).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v struct {
+			Hosts []string
+		}
+		err = cfg.Object(&v)
+		be, ok := err.(*BindError)
+		if !ok || len(be.Invalid) != 1 || !strings.HasPrefix(be.Invalid[0], "Hosts:") {
+			t.Errorf("%v: unexpected error %v", args, err)
+		}
+		if v.Hosts != nil {
+			t.Errorf("%v: Hosts = %v, want nil", args, v.Hosts)
+		}
+	}
+}
+
+func TestObjectKeyConflict(t *testing.T) {
+	cfg := NewConfiguration([]*Section{
+		{Name: "DEFAULT", Items: []*Item{{Key: "Db", Value: "x"}}},
+		{Name: "Db.Primary", Items: []*Item{{Key: "Host", Value: "a"}}},
+	})
+	var v struct {
+		Db struct {
+			Primary struct{ Host string }
+		}
+	}
+	if err := cfg.Object(&v); err == nil || !strings.Contains(err.Error(), "Db is both a value and a section") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg = NewConfiguration([]*Section{
+		{Name: "Db", Items: []*Item{{Key: "Primary", Value: "x"}}},
+		{Name: "Db.Primary", Items: []*Item{{Key: "Host", Value: "a"}}},
+	})
+	if err := cfg.Object(&v); err == nil || !strings.Contains(err.Error(), "Db:Primary is both a value and a section") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.Sections[0], cfg.Sections[1] = cfg.Sections[1], cfg.Sections[0]
+	if err := cfg.Object(&v); err == nil || !strings.Contains(err.Error(), "Db:Primary is both a value and a section") {
+		t.Errorf("unexpected error in reverse order: %v", err)
+	}
+}
@@ -22,6 +22,11 @@ func init() {
 	}
 }
 
+// IsEnvironment returns 当前环境是否为指定环境（不区分大小写）
+func IsEnvironment(name string) bool {
+	return strings.EqualFold(EnvironmentName, name)
+}
+
 // IsProduction returns 当前环境是否为生产环境
 func IsProduction() bool {
 	return strings.ToLower(EnvironmentName) == production
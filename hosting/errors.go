@@ -0,0 +1,87 @@
+package hosting
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/go-ini/ini"
+)
+
+// FileError is an error loading a configuration file. Line is the 1-based
+// line the parser stopped at and Context its text, when they are known.
+type FileError struct {
+	Path    string
+	Line    int
+	Context string
+	Err     error
+}
+
+func (e *FileError) Error() string {
+	msg := e.Path
+	if e.Line > 0 {
+		msg += ":" + strconv.Itoa(e.Line)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// MultiError is returned by Build when one or more sources failed to load.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strconv.Itoa(len(e)) + " configuration errors: " + strings.Join(msgs, "; ")
+}
+
+// iniErrorPrefixes are the go-ini parse errors that end with the offending line.
+var iniErrorPrefixes = []string{
+	"unclosed section: ",
+	"missing closing key quote: ",
+}
+
+func newFileError(path string, data []byte, err error) *FileError {
+	e := &FileError{Path: path, Err: err}
+	switch err := err.(type) {
+	case ini.ErrDelimiterNotFound:
+		e.Context = err.Line
+	case ini.ErrEmptyKeyName:
+		e.Context = err.Line
+	case *json.SyntaxError:
+		e.Line = lineAt(data, err.Offset)
+		return e
+	default:
+		for _, prefix := range iniErrorPrefixes {
+			if msg := err.Error(); strings.HasPrefix(msg, prefix) {
+				e.Context = strings.TrimPrefix(msg, prefix)
+			}
+		}
+	}
+	if e.Context = strings.TrimSpace(e.Context); e.Context != "" {
+		e.Line = lineOf(data, e.Context)
+	}
+	return e
+}
+
+// lineOf returns the first line of data that matches context, ignoring
+// surrounding space, or 0.
+func lineOf(data []byte, context string) int {
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == context {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return strings.Count(string(data[:offset]), "\n") + 1
+}
@@ -0,0 +1,169 @@
+package hosting
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-ini/ini"
+)
+
+// InterpolationError is an error resolving the references of the value at
+// Path.
+//
+// With ConfigurationBuilder.EnableInterpolation, values are interpolated
+// after Build has merged all sources:
+//
+//	${Section:Key}  is replaced by another value, looked up by path as in
+//	                GetValue, so ${Database:Host}, ${Database.Replicas:0:Host}
+//	                and ${Name} (default section) all work. References may
+//	                refer to values that contain references themselves.
+//	${env:NAME}     is replaced by the environment variable NAME.
+//	$$              is a literal "$", so $${Name} is left as ${Name}.
+//
+// Any other "$" is kept as is. A reference to a missing value, an unset
+// environment variable, an unterminated "${" or a reference cycle fails the
+// build with an *InterpolationError.
+type InterpolationError struct {
+	Path string
+	Err  error
+}
+
+func (e *InterpolationError) Error() string {
+	return "configuration value " + e.Path + ": " + e.Err.Error()
+}
+
+const (
+	unresolved = iota
+	resolving
+	resolved
+	failed
+)
+
+type interpolator struct {
+	cfg   *Configuration
+	state map[*Item]int
+	stack []reference
+	errs  MultiError
+}
+
+type reference struct {
+	item *Item
+	path string
+}
+
+// interpolate resolves the references of every value of cfg in place.
+func interpolate(cfg *Configuration) error {
+	in := &interpolator{cfg: cfg, state: make(map[*Item]int)}
+	for _, section := range cfg.Sections {
+		for _, item := range section.Items {
+			in.resolve(item, itemPath(section, item))
+		}
+	}
+	if len(in.errs) > 0 {
+		return in.errs
+	}
+	return nil
+}
+
+func itemPath(section *Section, item *Item) string {
+	if section.Name == ini.DefaultSection {
+		return item.Key
+	}
+	return section.Name + keyDelimiter + item.Key
+}
+
+// resolve expands the value of item and reports whether it succeeded. Each
+// failure is recorded once, on the value where it happened.
+func (in *interpolator) resolve(item *Item, path string) bool {
+	switch in.state[item] {
+	case resolved:
+		return true
+	case failed:
+		return false
+	case resolving:
+		var cycle []string
+		for i := len(in.stack) - 1; i >= 0; i-- {
+			cycle = append([]string{in.stack[i].path}, cycle...)
+			if in.stack[i].item == item {
+				break
+			}
+		}
+		cycle = append(cycle, path)
+		in.fail(path, errors.New("reference cycle "+strings.Join(cycle, " -> ")))
+		return false
+	}
+	if !strings.Contains(item.Value, "$") {
+		in.state[item] = resolved
+		return true
+	}
+
+	in.state[item] = resolving
+	in.stack = append(in.stack, reference{item, path})
+	value, ok := in.expand(item.Value, path)
+	in.stack = in.stack[:len(in.stack)-1]
+
+	if !ok {
+		in.state[item] = failed
+		return false
+	}
+	item.Value = value
+	in.state[item] = resolved
+	return true
+}
+
+func (in *interpolator) expand(s, path string) (string, bool) {
+	var buf strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			buf.WriteString(s)
+			return buf.String(), true
+		}
+		buf.WriteString(s[:i])
+		switch s[i+1] {
+		case '$':
+			buf.WriteByte('$')
+			s = s[i+2:]
+			continue
+		case '{':
+		default:
+			buf.WriteByte('$')
+			s = s[i+1:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			in.fail(path, fmt.Errorf("unterminated reference %q", s[i:]))
+			return "", false
+		}
+		name := s[i+2 : i+end]
+		s = s[i+end+1:]
+
+		if strings.HasPrefix(name, "env:") {
+			value, ok := os.LookupEnv(name[4:])
+			if !ok {
+				in.fail(path, fmt.Errorf("environment variable %s is not set", name[4:]))
+				return "", false
+			}
+			buf.WriteString(value)
+			continue
+		}
+
+		ref := in.cfg.item(name)
+		if ref == nil {
+			in.fail(path, fmt.Errorf("reference ${%s} not found", name))
+			return "", false
+		}
+		if !in.resolve(ref, name) {
+			return "", false
+		}
+		buf.WriteString(ref.Value)
+	}
+}
+
+func (in *interpolator) fail(path string, err error) {
+	in.errs = append(in.errs, &InterpolationError{Path: path, Err: err})
+}
@@ -0,0 +1,230 @@
+package hosting
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReloadableConfiguration is a configuration whose file sources are
+// reloaded when they change. The current configuration is swapped
+// atomically and must be treated as read-only.
+type ReloadableConfiguration struct {
+	sources     []configurationSource
+	stamps      []fileStamp
+	interpolate bool
+	current     atomic.Value // *Configuration
+
+	reloading sync.Mutex // serializes reloads
+	mu        sync.Mutex // guards handlers, onError and stop
+	handlers  []sectionHandler
+	onError   func(error)
+	stop      chan struct{}
+}
+
+type sectionHandler struct {
+	section string
+	fn      func(old, new *Section)
+}
+
+// fileStamp identifies the content of a file. Comparing content rather
+// than the modification time and size catches rewrites of the same size
+// within the timestamp resolution of the file system.
+type fileStamp struct {
+	exists bool
+	sum    [sha256.Size]byte
+}
+
+func stampOf(path string) fileStamp {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, sum: sha256.Sum256(buf)}
+}
+
+// BuildReloadable builds the configuration and, if interval is positive,
+// polls the file sources for changes every interval.
+func (this *ConfigurationBuilder) BuildReloadable(interval time.Duration) (*ReloadableConfiguration, error) {
+	sources, err := this.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ReloadableConfiguration{
+		sources:     sources,
+		stamps:      make([]fileStamp, len(sources)),
+		interpolate: this.interpolate,
+	}
+	for i, source := range sources {
+		if source.path != "" {
+			r.stamps[i] = stampOf(source.path)
+		}
+	}
+	cfg := mergeSources(sources)
+	if r.interpolate {
+		if err := interpolate(cfg); err != nil {
+			return nil, err
+		}
+	}
+	r.current.Store(cfg)
+
+	if interval > 0 {
+		r.stop = make(chan struct{})
+		go r.watch(interval, r.stop)
+	}
+	return r, nil
+}
+
+// Current returns the current configuration
+func (r *ReloadableConfiguration) Current() *Configuration {
+	return r.current.Load().(*Configuration)
+}
+
+// OnChange registers fn to be called when the named section changes after a
+// reload. old is nil if the section was added, new is nil if it was removed.
+// An empty name subscribes to every section.
+func (r *ReloadableConfiguration) OnChange(section string, fn func(old, new *Section)) {
+	r.mu.Lock()
+	r.handlers = append(r.handlers, sectionHandler{section: section, fn: fn})
+	r.mu.Unlock()
+}
+
+// OnError registers fn to be called when a polled reload fails
+func (r *ReloadableConfiguration) OnError(fn func(error)) {
+	r.mu.Lock()
+	r.onError = fn
+	r.mu.Unlock()
+}
+
+// Reload reads every file source again. On error the current configuration
+// is kept.
+func (r *ReloadableConfiguration) Reload() error {
+	return r.reload(true)
+}
+
+// Close stops polling
+func (r *ReloadableConfiguration) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+func (r *ReloadableConfiguration) watch(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.reload(false); err != nil {
+				r.mu.Lock()
+				onError := r.onError
+				r.mu.Unlock()
+				if onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}
+}
+
+func (r *ReloadableConfiguration) reload(force bool) error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+
+	sources := make([]configurationSource, len(r.sources))
+	copy(sources, r.sources)
+	stamps := make([]fileStamp, len(r.stamps))
+	copy(stamps, r.stamps)
+
+	changed := false
+	for i := range sources {
+		source := &sources[i]
+		if source.path == "" {
+			continue
+		}
+		stamp := stampOf(source.path)
+		if !force && stamp == stamps[i] {
+			continue
+		}
+		c, err := source.read()
+		if err != nil {
+			return err
+		}
+		source.config = c
+		stamps[i] = stamp
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	cfg := mergeSources(sources)
+	if r.interpolate {
+		if err := interpolate(cfg); err != nil {
+			return err
+		}
+	}
+	old := r.Current()
+	r.sources, r.stamps = sources, stamps
+	r.current.Store(cfg)
+
+	r.notify(old, cfg)
+	return nil
+}
+
+func (r *ReloadableConfiguration) notify(old, new *Configuration) {
+	r.mu.Lock()
+	handlers := make([]sectionHandler, len(r.handlers))
+	copy(handlers, r.handlers)
+	r.mu.Unlock()
+	if len(handlers) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(new.Sections))
+	seen := make(map[string]bool)
+	for _, cfg := range []*Configuration{old, new} {
+		for _, section := range cfg.Sections {
+			if !seen[section.Name] {
+				seen[section.Name] = true
+				names = append(names, section.Name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		before, after := old.Section(name), new.Section(name)
+		if sectionsEqual(before, after) {
+			continue
+		}
+		for _, h := range handlers {
+			if h.section == "" || h.section == name {
+				h.fn(before, after)
+			}
+		}
+	}
+}
+
+func sectionsEqual(a, b *Section) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if len(a.Items) != len(b.Items) {
+		return false
+	}
+	for i := range a.Items {
+		if a.Items[i].Key != b.Items[i].Key || a.Items[i].Value != b.Items[i].Value {
+			return false
+		}
+	}
+	return true
+}
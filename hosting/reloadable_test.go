@@ -0,0 +1,142 @@
+package hosting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadableConfiguration(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "app.ini", "[Database]\nHost = a\nPort = 3306\n[Cache]\nSize = 1\n")
+
+	r, err := NewConfigurationBuilder().
+		SetBasePath(dir).
+		AddIniFile("app.ini").
+		AddJsonFileOptional("app.local.json").
+		AddCommandLine([]string{"--Database:Port=3307"}).
+		BuildReloadable(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var changed []string
+	r.OnChange("Database", func(old, new *Section) {
+		changed = append(changed, old.Item("Host").Value+">"+new.Item("Host").Value)
+	})
+	var added []string
+	r.OnChange("", func(old, new *Section) {
+		if old == nil {
+			added = append(added, new.Name)
+		}
+	})
+
+	first := r.Current()
+	writeFile(t, dir, "app.ini", "[Database]\nHost = b\nPort = 3306\n[Cache]\nSize = 1\n")
+	writeFile(t, dir, "app.local.json", `{"Feature": {"On": "true"}}`)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := r.Current()
+	if cfg == first {
+		t.Fatal("expected a new configuration")
+	}
+	if v, _ := first.GetValue("Database:Host"); v != "a" {
+		t.Errorf("old configuration was modified: %q", v)
+	}
+	if v, _ := cfg.GetValue("Database:Host"); v != "b" {
+		t.Errorf("Database:Host = %q", v)
+	}
+	if v, _ := cfg.GetValue("Database:Port"); v != "3307" {
+		t.Errorf("command line override lost: %q", v)
+	}
+	if v, _ := cfg.GetValue("Feature:On"); v != "true" {
+		t.Errorf("optional file not picked up: %q", v)
+	}
+	if len(changed) != 1 || changed[0] != "a>b" {
+		t.Errorf("changed = %v", changed)
+	}
+	if len(added) != 1 || added[0] != "Feature" {
+		t.Errorf("added = %v", added)
+	}
+
+	writeFile(t, dir, "app.ini", "[Database\n")
+	if err := r.Reload(); err == nil {
+		t.Error("expected reload error")
+	}
+	if r.Current() != cfg {
+		t.Error("failed reload replaced the configuration")
+	}
+}
+
+func TestReloadableConfigurationSameSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "app.ini", "[Database]\nHost = a\n")
+	path := filepath.Join(dir, "app.ini")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewConfigurationBuilder().
+		SetBasePath(dir).
+		AddIniFile("app.ini").
+		BuildReloadable(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writeFile(t, dir, "app.ini", "[Database]\nHost = b\n")
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	// as the poller does, reload only what changed
+	if err := r.reload(false); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Current().GetValue("Database:Host"); v != "b" {
+		t.Errorf("Database:Host = %q, want b", v)
+	}
+}
+
+func TestReloadableConfigurationWatch(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "app.ini", "[Database]\nHost = a\n")
+
+	r, err := NewConfigurationBuilder().
+		SetBasePath(dir).
+		AddIniFile("app.ini").
+		BuildReloadable(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	done := make(chan string, 1)
+	r.OnChange("Database", func(old, new *Section) {
+		select {
+		case done <- new.Item("Host").Value:
+		default:
+		}
+	})
+
+	writeFile(t, dir, "app.ini", "[Database]\nHost = watched\n")
+	select {
+	case host := <-done:
+		if host != "watched" {
+			t.Errorf("Host = %q", host)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not detected")
+	}
+	if v, _ := r.Current().GetValue("Database:Host"); v != "watched" {
+		t.Errorf("Database:Host = %q", v)
+	}
+}
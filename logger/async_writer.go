@@ -0,0 +1,220 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrWriterClosed is returned by writes to a closed AsyncWriter
+var ErrWriterClosed = errors.New("logger: writer closed")
+
+// OverflowPolicy decides what an AsyncWriter does with a message when its
+// queue is full
+type OverflowPolicy int
+
+// Overflow policies
+const (
+	// OverflowBlock waits until there is room in the queue
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the message being written
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued message
+	OverflowDropOldest
+	// OverflowSample keeps one in SampleRate messages, waiting for room,
+	// and drops the others
+	OverflowSample
+)
+
+var overflowPolicyNames = map[string]OverflowPolicy{
+	"block":       OverflowBlock,
+	"drop-newest": OverflowDropNewest,
+	"drop-oldest": OverflowDropOldest,
+	"sample":      OverflowSample,
+}
+
+// ParseOverflowPolicy parses block, drop-newest, drop-oldest or sample
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	if p, ok := overflowPolicyNames[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	return 0, fmt.Errorf("invalid overflow policy: %s", s)
+}
+
+// DefaultSampleRate is the SampleRate of a new AsyncWriter
+const DefaultSampleRate = 10
+
+type asyncEntry struct {
+	data   []byte
+	record *Record
+}
+
+// AsyncWriter is an io.Writer and RecordWriter that queues writes and
+// passes them on to another writer from its own goroutine, so that a slow
+// writer does not stall the callers.
+type AsyncWriter struct {
+	// SampleRate is used by OverflowSample. It must be set before the
+	// first write.
+	SampleRate int
+
+	w      io.Writer
+	policy OverflowPolicy
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	queue    []asyncEntry // ring buffer
+	head     int
+	n        int
+	busy     bool // an entry is being written
+	closed   bool
+	dropped  uint64
+	overflow uint64
+	done     chan struct{}
+}
+
+// NewAsyncWriter creates an AsyncWriter writing to w with room for size
+// queued messages
+func NewAsyncWriter(w io.Writer, size int, policy OverflowPolicy) *AsyncWriter {
+	if size <= 0 {
+		size = 1
+	}
+	a := &AsyncWriter{
+		SampleRate: DefaultSampleRate,
+		w:          w,
+		policy:     policy,
+		queue:      make([]asyncEntry, size),
+		done:       make(chan struct{}),
+	}
+	a.notEmpty = sync.NewCond(&a.mu)
+	a.notFull = sync.NewCond(&a.mu)
+	a.idle = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+// Write is used to implement io.Writer. p is copied before it is queued.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	p2 := make([]byte, len(p))
+	copy(p2, p)
+	if err := a.enqueue(asyncEntry{data: p2}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteRecord is used to implement RecordWriter. r is copied before it is
+// queued.
+func (a *AsyncWriter) WriteRecord(r *Record) error {
+	r2 := *r
+	r2.Fields = make([]Field, len(r.Fields))
+	copy(r2.Fields, r.Fields)
+	return a.enqueue(asyncEntry{record: &r2})
+}
+
+// Flush waits until every queued message has been written
+func (a *AsyncWriter) Flush() {
+	a.mu.Lock()
+	for a.n > 0 || a.busy {
+		a.idle.Wait()
+	}
+	a.mu.Unlock()
+}
+
+// Close writes the queued messages and stops the goroutine. Later writes
+// return ErrWriterClosed.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.notEmpty.Broadcast()
+	a.notFull.Broadcast()
+	a.mu.Unlock()
+
+	<-a.done
+	return nil
+}
+
+// Dropped returns the number of messages dropped because the queue was full
+func (a *AsyncWriter) Dropped() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.dropped
+}
+
+func (a *AsyncWriter) enqueue(e asyncEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sampled := false
+	for !a.closed && a.n == len(a.queue) {
+		switch a.policy {
+		case OverflowDropNewest:
+			a.dropped++
+			return nil
+		case OverflowDropOldest:
+			a.queue[a.head] = asyncEntry{}
+			a.head = (a.head + 1) % len(a.queue)
+			a.n--
+			a.dropped++
+		case OverflowSample:
+			if !sampled {
+				a.overflow++
+				if a.SampleRate > 1 && a.overflow%uint64(a.SampleRate) != 0 {
+					a.dropped++
+					return nil
+				}
+				sampled = true
+			}
+			a.notFull.Wait()
+		default:
+			a.notFull.Wait()
+		}
+	}
+	if a.closed {
+		return ErrWriterClosed
+	}
+
+	a.queue[(a.head+a.n)%len(a.queue)] = e
+	a.n++
+	a.notEmpty.Signal()
+	return nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+
+	for {
+		a.mu.Lock()
+		for a.n == 0 && !a.closed {
+			a.notEmpty.Wait()
+		}
+		if a.n == 0 {
+			a.idle.Broadcast()
+			a.mu.Unlock()
+			return
+		}
+		e := a.queue[a.head]
+		a.queue[a.head] = asyncEntry{}
+		a.head = (a.head + 1) % len(a.queue)
+		a.n--
+		a.busy = true
+		a.notFull.Signal()
+		a.mu.Unlock()
+
+		if e.record != nil {
+			writeRecord(a.w, nil, e.record)
+		} else {
+			a.w.Write(e.data)
+		}
+
+		a.mu.Lock()
+		a.busy = false
+		if a.n == 0 {
+			a.idle.Broadcast()
+		}
+		a.mu.Unlock()
+	}
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// slowWriter blocks every write until release is closed. started is closed
+// when the first write begins.
+type slowWriter struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	lines   []string
+}
+
+func newSlowWriter() *slowWriter {
+	return &slowWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	w.mu.Lock()
+	w.lines = append(w.lines, string(p))
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func TestAsyncWriterPolicies(t *testing.T) {
+	tests := []struct {
+		policy  OverflowPolicy
+		want    string
+		dropped uint64
+	}{
+		{OverflowDropNewest, "0,1,2", 5},
+		{OverflowDropOldest, "0,6,7", 5},
+	}
+	for _, test := range tests {
+		w := newSlowWriter()
+		a := NewAsyncWriter(w, 2, test.policy)
+
+		a.Write([]byte("0"))
+		<-w.started
+		for i := 1; i < 8; i++ {
+			a.Write([]byte(strconv.Itoa(i)))
+		}
+		close(w.release)
+		a.Flush()
+
+		if got := strings.Join(w.lines, ","); got != test.want {
+			t.Errorf("policy %d wrote %s, want %s", test.policy, got, test.want)
+		}
+		if d := a.Dropped(); d != test.dropped {
+			t.Errorf("policy %d dropped %d, want %d", test.policy, d, test.dropped)
+		}
+		a.Close()
+	}
+}
+
+func TestAsyncWriterSample(t *testing.T) {
+	w := newSlowWriter()
+	a := NewAsyncWriter(w, 2, OverflowSample)
+	a.SampleRate = 3
+
+	a.Write([]byte("0"))
+	<-w.started
+	for i := 1; i < 5; i++ {
+		a.Write([]byte(strconv.Itoa(i)))
+	}
+	done := make(chan struct{})
+	go func() {
+		// the third message to overflow is kept and waits for room
+		a.Write([]byte("5"))
+		close(done)
+	}()
+	close(w.release)
+	<-done
+	a.Close()
+
+	if got := strings.Join(w.lines, ","); got != "0,1,2,5" || a.Dropped() != 2 {
+		t.Errorf("wrote %s, dropped %d", got, a.Dropped())
+	}
+}
+
+func TestAsyncWriterBlockAndClose(t *testing.T) {
+	w := newSlowWriter()
+	a := NewAsyncWriter(w, 1, OverflowBlock)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			a.Write([]byte(strconv.Itoa(i)))
+		}
+		close(done)
+	}()
+	close(w.release)
+	<-done
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(w.lines, ","); got != "0,1,2,3,4" || a.Dropped() != 0 {
+		t.Errorf("wrote %s, dropped %d", got, a.Dropped())
+	}
+	if _, err := a.Write([]byte("late")); err != ErrWriterClosed {
+		t.Errorf("expected ErrWriterClosed, got %v", err)
+	}
+}
+
+func TestAsyncWriterRecords(t *testing.T) {
+	defer fixedTime()()
+
+	var buf bytes.Buffer
+	filter := NewLevelFilter()
+	filter.Writer = &buf
+	filter.Encoder = LogfmtEncoder{}
+	a := NewAsyncWriter(filter, 16, OverflowBlock)
+
+	log := New(a).WithCaller(false).With("a", 1)
+	log.Debug("hidden")
+	log.Info("shown", "b", 2)
+	a.Flush()
+
+	if want := "ts=2018-03-01T08:30:00Z level=INFO msg=shown a=1 b=2\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	a.Close()
+}
@@ -3,8 +3,10 @@
 package logger
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log/syslog"
 	"net"
 	"os"
@@ -17,6 +19,19 @@ const severityMask = 0x07
 const facilityMask = 0xf8
 const localDeadline = 20 * time.Millisecond
 const remoteDeadline = 50 * time.Millisecond
+const tlsHandshakeTimeout = 5 * time.Second
+
+var errOctetCountingDatagram = errors.New("log/syslog: octet counting needs a stream network such as tcp")
+
+// isDatagram reports whether messages on network are sent as datagrams,
+// which carry their own length
+func isDatagram(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return true
+	}
+	return false
+}
 
 // A builtinWriter is a connection to a syslog server.
 type builtinWriter struct {
@@ -26,10 +41,23 @@ type builtinWriter struct {
 	network  string
 	raddr    string
 
+	format        SyslogFormat
+	octetCounting bool
+	tlsConfig     *tls.Config
+	sdID          string
+
 	mu   sync.Mutex // guards conn
 	conn serverConn
 }
 
+// message is a log message with the RFC 5424 MSGID and STRUCTURED-DATA,
+// which are empty for plain writes.
+type message struct {
+	msg   string
+	msgID string
+	sd    string
+}
+
 // This interface and the separate syslog_unix.go file exist for
 // Solaris support as implemented by gccgo.  On Solaris you can not
 // simply open a TCP connection to the syslog daemon.  The gccgo
@@ -38,12 +66,14 @@ type builtinWriter struct {
 // library syslog function.
 type serverConn interface {
 	writeString(p syslog.Priority, hostname, tag, s, nl string) error
+	writeFrame(msg string) error
 	close() error
 }
 
 type netConn struct {
-	local bool
-	conn  net.Conn
+	local         bool
+	octetCounting bool
+	conn          net.Conn
 }
 
 // New establishes a new connection to the system log daemon.  Each
@@ -59,21 +89,29 @@ func newBuiltin(priority syslog.Priority, tag string) (w *builtinWriter, err err
 // tag.
 // If network is empty, Dial will connect to the local syslog server.
 func dialBuiltin(network, raddr string, priority syslog.Priority, tag string) (*builtinWriter, error) {
-	if priority < 0 || priority > syslog.LOG_LOCAL7|syslog.LOG_DEBUG {
-		return nil, errors.New("log/syslog: invalid priority")
-	}
-
-	if tag == "" {
-		tag = os.Args[0]
-	}
-	hostname, _ := os.Hostname()
-
-	w := &builtinWriter{
+	return dialBuiltinWriter(&builtinWriter{
 		priority: priority,
 		tag:      tag,
-		hostname: hostname,
 		network:  network,
 		raddr:    raddr,
+	})
+}
+
+// dialBuiltinWriter connects w, which has its options set.
+func dialBuiltinWriter(w *builtinWriter) (*builtinWriter, error) {
+	if w.priority < 0 || w.priority > syslog.LOG_LOCAL7|syslog.LOG_DEBUG {
+		return nil, errors.New("log/syslog: invalid priority")
+	}
+
+	if w.tag == "" {
+		w.tag = os.Args[0]
+	}
+	w.hostname, _ = os.Hostname()
+	if w.sdID == "" {
+		w.sdID = DefaultSDID
+	}
+	if w.octetCounting && isDatagram(w.network) {
+		return nil, errOctetCountingDatagram
 	}
 
 	w.mu.Lock()
@@ -102,9 +140,14 @@ func (w *builtinWriter) connect() (err error) {
 		}
 	} else {
 		var c net.Conn
-		c, err = net.DialTimeout(w.network, w.raddr, remoteDeadline)
+		if w.tlsConfig != nil {
+			dialer := &net.Dialer{Timeout: tlsHandshakeTimeout}
+			c, err = tls.DialWithDialer(dialer, w.network, w.raddr, w.tlsConfig)
+		} else {
+			c, err = net.DialTimeout(w.network, w.raddr, remoteDeadline)
+		}
 		if err == nil {
-			w.conn = &netConn{conn: c}
+			w.conn = &netConn{conn: c, octetCounting: w.octetCounting || w.tlsConfig != nil}
 			if w.hostname == "" {
 				w.hostname = c.LocalAddr().String()
 			}
@@ -115,7 +158,7 @@ func (w *builtinWriter) connect() (err error) {
 
 // Write sends a log message to the syslog daemon.
 func (w *builtinWriter) Write(b []byte) (int, error) {
-	return w.writeAndRetry(w.priority, string(b))
+	return w.writeAndRetry(w.priority, message{msg: string(b)})
 }
 
 // Close closes a connection to the syslog daemon.
@@ -131,40 +174,46 @@ func (w *builtinWriter) Close() error {
 	return nil
 }
 
-func (w *builtinWriter) writeAndRetry(p syslog.Priority, s string) (int, error) {
+func (w *builtinWriter) writeAndRetry(p syslog.Priority, m message) (int, error) {
 	pr := (w.priority & facilityMask) | (p & severityMask)
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	if w.conn != nil {
-		if n, err := w.write(pr, s); err == nil {
+		if n, err := w.write(pr, m); err == nil {
 			return n, err
 		}
 	}
 	if err := w.connect(); err != nil {
 		return 0, err
 	}
-	return w.write(pr, s)
+	return w.write(pr, m)
 }
 
 // write generates and writes a syslog formatted string. The
 // format is as follows: <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
-func (w *builtinWriter) write(p syslog.Priority, msg string) (int, error) {
-	// ensure it ends in a \n
-	nl := ""
-	if !strings.HasSuffix(msg, "\n") {
-		nl = "\n"
+// or, for RFC 5424:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (w *builtinWriter) write(p syslog.Priority, m message) (int, error) {
+	var err error
+	if w.format == SyslogRFC5424 {
+		err = w.conn.writeFrame(formatRFC5424(p, now(), w.hostname, w.tag, m))
+	} else {
+		// ensure it ends in a \n
+		nl := ""
+		if !strings.HasSuffix(m.msg, "\n") {
+			nl = "\n"
+		}
+		err = w.conn.writeString(p, w.hostname, w.tag, m.msg, nl)
 	}
-
-	err := w.conn.writeString(p, w.hostname, w.tag, msg, nl)
 	if err != nil {
 		return 0, err
 	}
 	// Note: return the length of the input, not the number of
 	// bytes printed by Fprintf, because this must behave like
 	// an io.Writer.
-	return len(msg), nil
+	return len(m.msg), nil
 }
 
 func (n *netConn) writeString(p syslog.Priority, hostname, tag, msg, nl string) error {
@@ -173,17 +222,40 @@ func (n *netConn) writeString(p syslog.Priority, hostname, tag, msg, nl string)
 		//	1. Use time.Stamp instead of time.RFC3339.
 		//	2. Drop the hostname field from the Fprintf.
 		timestamp := time.Now().Format(time.Stamp)
-		n.conn.SetWriteDeadline(time.Now().Add(localDeadline))
-		_, err := fmt.Fprintf(n.conn, "<%d>%s %s[%d]: %s%s",
+		return n.writeFrame(fmt.Sprintf("<%d>%s %s[%d]: %s%s",
 			p, timestamp,
-			tag, os.Getpid(), msg, nl)
-		return err
+			tag, os.Getpid(), msg, nl))
 	}
 	timestamp := time.Now().Format(time.RFC3339)
-	n.conn.SetWriteDeadline(time.Now().Add(remoteDeadline))
-	_, err := fmt.Fprintf(n.conn, "<%d>%s %s %s[%d]: %s%s",
+	return n.writeFrame(fmt.Sprintf("<%d>%s %s %s[%d]: %s%s",
 		p, timestamp, hostname,
-		tag, os.Getpid(), msg, nl)
+		tag, os.Getpid(), msg, nl))
+}
+
+// writeFrame writes a formatted message. With octet counting (RFC 6587)
+// the message is prefixed with its length and a trailing newline is
+// dropped; otherwise one is added if missing. Octet counting is refused on
+// datagram connections.
+func (n *netConn) writeFrame(msg string) error {
+	if n.local {
+		n.conn.SetWriteDeadline(time.Now().Add(localDeadline))
+	} else {
+		n.conn.SetWriteDeadline(time.Now().Add(remoteDeadline))
+	}
+
+	var err error
+	if n.octetCounting {
+		if isDatagram(n.conn.LocalAddr().Network()) {
+			return errOctetCountingDatagram
+		}
+		msg = strings.TrimSuffix(msg, "\n")
+		_, err = fmt.Fprintf(n.conn, "%d %s", len(msg), msg)
+	} else {
+		if !strings.HasSuffix(msg, "\n") {
+			msg += "\n"
+		}
+		_, err = io.WriteString(n.conn, msg)
+	}
 	return err
 }
 
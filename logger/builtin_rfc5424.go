@@ -0,0 +1,106 @@
+// +build !windows,!nacl,!plan9
+
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"log/syslog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syslogPriority converts a severity to a syslog priority.
+func syslogPriority(p Priority) (syslog.Priority, error) {
+	if p < LOG_EMERG || p > LOG_DEBUG {
+		return 0, errors.New("Unknown priority: " + strconv.Itoa(int(p)))
+	}
+	return syslog.Priority(p), nil
+}
+
+// recordMessage returns the message of r for syslog. For RFC 5424 the
+// fields go into STRUCTURED-DATA and the MsgIDKey field into MSGID;
+// otherwise they are appended to the message as text.
+func recordMessage(format SyslogFormat, sdID string, r *Record) message {
+	if format != SyslogRFC5424 {
+		return message{msg: string(r.appendMessage(nil))}
+	}
+
+	plain := *r
+	plain.Fields = nil
+	m := message{msg: string(plain.appendMessage(nil))}
+
+	var sd []byte
+	for _, f := range r.Fields {
+		if f.Key == MsgIDKey && m.msgID == "" {
+			m.msgID = formatValue(f.Value)
+			continue
+		}
+		name := sdName(f.Key)
+		if name == "" {
+			continue
+		}
+		if sd == nil {
+			sd = append(sd, '[')
+			sd = append(sd, sdID...)
+		}
+		sd = append(sd, ' ')
+		sd = append(sd, name...)
+		sd = append(sd, `="`...)
+		sd = append(sd, sdValueEscaper.Replace(formatValue(f.Value))...)
+		sd = append(sd, '"')
+	}
+	if sd != nil {
+		m.sd = string(append(sd, ']'))
+	}
+	return m
+}
+
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// sdName returns key as an SD-NAME: at most 32 printable US-ASCII
+// characters other than '=', ' ', ']' and '"'.
+func sdName(key string) string {
+	name := make([]byte, 0, len(key))
+	for i := 0; i < len(key) && len(name) < 32; i++ {
+		c := key[i]
+		if c > ' ' && c < 0x7f && c != '=' && c != ']' && c != '"' {
+			name = append(name, c)
+		}
+	}
+	return string(name)
+}
+
+// headerField returns s as an RFC 5424 header field of at most max
+// printable US-ASCII characters, or "-" if it is empty.
+func headerField(s string, max int) string {
+	field := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(field) < max; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			field = append(field, c)
+		}
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
+
+// formatRFC5424 formats a message as
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func formatRFC5424(p syslog.Priority, t time.Time, hostname, appName string, m message) string {
+	sd := m.sd
+	if sd == "" {
+		sd = "-"
+	}
+	s := fmt.Sprintf("<%d>1 %s %s %s %d %s %s",
+		p, t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(hostname, 255), headerField(appName, 48),
+		os.Getpid(), headerField(m.msgID, 32), sd)
+	if msg := strings.TrimSuffix(m.msg, "\n"); msg != "" {
+		s += " " + msg
+	}
+	return s
+}
@@ -0,0 +1,268 @@
+// +build !windows,!nacl,!plan9
+
+package logger
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatRFC5424(t *testing.T) {
+	r := &Record{
+		Level:   LevelWarn,
+		Caller:  "main.go:7",
+		Message: "slow query",
+		Fields: []Field{
+			{"msgid", "DBSLOW"},
+			{"sql", `select "a" [x]`},
+			{"bad key=", 1},
+		},
+	}
+	m := recordMessage(SyslogRFC5424, DefaultSDID, r)
+	got := formatRFC5424(syslog.LOG_LOCAL0|syslog.LOG_WARNING, time.Date(2018, 3, 1, 8, 30, 0, 1000, time.UTC), "web 1", "orders", m)
+	want := `<132>1 2018-03-01T08:30:00.000001Z web1 orders ` + strconv.Itoa(os.Getpid()) +
+		` DBSLOW [fields@32473 sql="select \"a\" [x\]" badkey="1"] main.go:7: slow query`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	m = recordMessage(SyslogRFC5424, DefaultSDID, &Record{Message: "plain"})
+	if got := formatRFC5424(syslog.LOG_INFO, time.Time{}, "", "", m); !strings.HasSuffix(got, " - - plain") {
+		t.Errorf("got %s", got)
+	}
+	if m := recordMessage(SyslogRFC3164, DefaultSDID, r); !strings.HasSuffix(m.msg, "slow query msgid=DBSLOW sql=\"select \\\"a\\\" [x]\" \"bad key=\"=1") {
+		t.Errorf("rfc3164 message = %q", m.msg)
+	}
+}
+
+// readFrame reads an octet-counted (RFC 6587) message.
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	length, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func acceptOne(t *testing.T, l net.Listener) <-chan net.Conn {
+	ch := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			close(ch)
+			return
+		}
+		// a TLS server only handshakes on its first read, which the
+		// client would wait for when dialing
+		if tc, ok := c.(*tls.Conn); ok {
+			if err := tc.Handshake(); err != nil {
+				t.Error(err)
+			}
+		}
+		ch <- c
+	}()
+	return ch
+}
+
+func TestSyslogOctetCounting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conns := acceptOne(t, l)
+
+	s, err := DialSyslog(&SyslogConfig{
+		Network:       "tcp",
+		Address:       l.Addr().String(),
+		Priority:      LOG_NOTICE,
+		Facility:      "LOCAL1",
+		Tag:           "orders",
+		Format:        SyslogRFC5424,
+		OctetCounting: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	filter := NewLevelFilter()
+	log := New(&SyslogWrapper{s, filter}).WithCaller(false)
+	log.Warn("first line", "user", "bob")
+	log.Info("second\nline")
+
+	c := <-conns
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(c)
+
+	first := regexp.MustCompile(`^<140>1 \S+ \S+ orders \d+ - \[fields@32473 user="bob"\] first line$`)
+	if msg := readFrame(t, r); !first.MatchString(msg) {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if msg := readFrame(t, r); !strings.HasSuffix(msg, " - - second\nline") || !strings.HasPrefix(msg, "<141>1 ") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestSyslogOctetCountingDatagram(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = DialSyslog(&SyslogConfig{
+		Network:       "udp",
+		Address:       c.LocalAddr().String(),
+		Priority:      LOG_NOTICE,
+		Facility:      "LOCAL1",
+		OctetCounting: true,
+	})
+	if err != errOctetCountingDatagram {
+		t.Errorf("DialSyslog over udp = %v, want %v", err, errOctetCountingDatagram)
+	}
+
+	conn, err := net.Dial("udp", c.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &netConn{conn: conn, octetCounting: true}
+	defer n.close()
+	if err := n.writeFrame("message"); err != errOctetCountingDatagram {
+		t.Errorf("writeFrame over udp = %v, want %v", err, errOctetCountingDatagram)
+	}
+}
+
+// writeCert writes a certificate and key signed by parent, or self-signed
+// if parent is nil, to dir/name.crt and dir/name.key.
+func writeCert(t *testing.T, dir, name string, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600)
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.Leaf, _ = x509.ParseCertificate(der)
+	return cert
+}
+
+func TestSyslogTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := writeCert(t, dir, "ca", &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server := writeCert(t, dir, "server", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, &ca)
+	writeCert(t, dir, "client", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conns := acceptOne(t, l)
+
+	tlsConfig, err := NewSyslogTLSConfig(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := DialSyslog(&SyslogConfig{
+		Network:   "tcp",
+		Address:   l.Addr().String(),
+		Priority:  LOG_NOTICE,
+		Facility:  "USER",
+		Tag:       "orders",
+		Format:    SyslogRFC5424,
+		TLSConfig: tlsConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.WriteLevel(LOG_ERR, []byte("over tls")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := <-conns
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	tc := c.(*tls.Conn)
+	msg := readFrame(t, bufio.NewReader(tc))
+	if !strings.HasPrefix(msg, "<11>1 ") || !strings.HasSuffix(msg, " orders "+strconv.Itoa(os.Getpid())+" - - over tls") {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if peers := tc.ConnectionState().PeerCertificates; len(peers) == 0 || peers[0].Subject.CommonName != "client" {
+		t.Error("client certificate not presented")
+	}
+}
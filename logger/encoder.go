@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encoder appends a record to buf as a single line, including the trailing
+// newline
+type Encoder interface {
+	Encode(buf []byte, r *Record) []byte
+}
+
+// TextEncoder encodes records as
+// 2006/01/02 15:04:05 [LEVEL] file.go:12: message key=value
+type TextEncoder struct{}
+
+// Encode is used to implement Encoder
+func (TextEncoder) Encode(buf []byte, r *Record) []byte {
+	return r.appendText(buf)
+}
+
+// JSONEncoder encodes records as JSON objects with the keys ts, level,
+// caller and msg followed by the fields
+type JSONEncoder struct{}
+
+// Encode is used to implement Encoder
+func (JSONEncoder) Encode(buf []byte, r *Record) []byte {
+	buf = append(buf, `{"ts":`...)
+	buf = strconv.AppendQuote(buf, r.Time.Format(time.RFC3339Nano))
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONString(buf, string(r.Level))
+	if r.Caller != "" {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSONString(buf, r.Caller)
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, r.Message)
+	for _, f := range r.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, f.Value)
+	}
+	return append(buf, "}\n"...)
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	b, _ := json.Marshal(s)
+	return append(buf, b...)
+}
+
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case error:
+		return appendJSONString(buf, v.Error())
+	case json.Marshaler:
+	case fmt.Stringer:
+		return appendJSONString(buf, v.String())
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprint(v))
+	}
+	return append(buf, b...)
+}
+
+// LogfmtEncoder encodes records as logfmt key=value pairs with the keys ts,
+// level, caller and msg followed by the fields
+type LogfmtEncoder struct{}
+
+// Encode is used to implement Encoder
+func (LogfmtEncoder) Encode(buf []byte, r *Record) []byte {
+	buf = append(buf, "ts="...)
+	buf = r.Time.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, " level="...)
+	buf = appendTextValue(buf, string(r.Level))
+	if r.Caller != "" {
+		buf = append(buf, " caller="...)
+		buf = appendTextValue(buf, r.Caller)
+	}
+	buf = append(buf, " msg="...)
+	buf = appendTextValue(buf, r.Message)
+	for _, f := range r.Fields {
+		buf = append(buf, ' ')
+		buf = appendTextValue(buf, f.Key)
+		buf = append(buf, '=')
+		buf = appendTextValue(buf, formatValue(f.Value))
+	}
+	return append(buf, '\n')
+}
+
+// NewEncoder returns the encoder for a log format: "text" (the default),
+// "json" or "logfmt"
+func NewEncoder(format string) (Encoder, error) {
+	switch strings.ToLower(format) {
+	case "", "text":
+		return TextEncoder{}, nil
+	case "json":
+		return JSONEncoder{}, nil
+	case "logfmt":
+		return LogfmtEncoder{}, nil
+	}
+	return nil, fmt.Errorf("invalid log format: %s", format)
+}
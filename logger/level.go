@@ -16,6 +16,10 @@ type LevelFilter struct {
 	MinLevel LogLevel
 	Writer   io.Writer
 
+	// Encoder encodes records for a Writer that is not a RecordWriter.
+	// Nil means TextEncoder.
+	Encoder Encoder
+
 	badLevels map[LogLevel]struct{}
 	once      sync.Once
 }
@@ -23,8 +27,6 @@ type LevelFilter struct {
 // Check will check a given line if it would be included in the level
 // filter.
 func (f *LevelFilter) Check(line []byte) bool {
-	f.once.Do(f.init)
-
 	var level LogLevel
 	x := bytes.IndexByte(line, '[')
 	if x >= 0 {
@@ -34,6 +36,14 @@ func (f *LevelFilter) Check(line []byte) bool {
 		}
 	}
 
+	return f.Enabled(level)
+}
+
+// Enabled will check if a given level would be included in the level
+// filter.
+func (f *LevelFilter) Enabled(level LogLevel) bool {
+	f.once.Do(f.init)
+
 	_, ok := f.badLevels[level]
 	return !ok
 }
@@ -47,6 +57,15 @@ func (f *LevelFilter) Write(p []byte) (n int, err error) {
 	return f.Writer.Write(p)
 }
 
+// WriteRecord is used to implement RecordWriter
+func (f *LevelFilter) WriteRecord(r *Record) error {
+	if !f.Enabled(r.Level) {
+		return nil
+	}
+
+	return writeRecord(f.Writer, f.Encoder, r)
+}
+
 // SetMinLevel is used to update the minimum log level
 func (f *LevelFilter) SetMinLevel(min LogLevel) {
 	f.MinLevel = min
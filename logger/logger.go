@@ -11,12 +11,102 @@ import (
 // Config is used to set up logging.
 type Config struct {
 	LogLevel       string
+	LogFormat      string // text, json or logfmt, see NewEncoder
 	EnableSyslog   bool
 	SyslogFacility string
+
+	// SyslogNetwork and SyslogAddress select a remote syslog server
+	// instead of the local one. SyslogFormat is rfc3164 (the default) or
+	// rfc5424. SyslogTLS connects over TLS, trusting SyslogTLSCAFile or
+	// the system roots and presenting the client certificate in
+	// SyslogTLSCertFile and SyslogTLSKeyFile, if set. SyslogOctetCounting
+	// frames messages with their length instead of a newline on stream
+	// networks; TLS always does.
+	SyslogNetwork       string
+	SyslogAddress       string
+	SyslogFormat        string
+	SyslogOctetCounting bool
+	SyslogTLS           bool
+	SyslogTLSCAFile     string
+	SyslogTLSCertFile   string
+	SyslogTLSKeyFile    string
+
+	// LogFile enables a file sink that rotates daily and when it reaches
+	// MaxSizeMB, keeping MaxBackups backups for MaxAgeDays days (0 keeps
+	// them all), optionally gzipped. SIGHUP reopens the file.
+	LogFile    string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
+
+	// AsyncQueueSize, when positive, puts the LogWriter and syslog sinks
+	// behind an AsyncWriter with room for that many messages, and sizes the
+	// queue of the file sink, which is always asynchronous. AsyncPolicy is
+	// block, drop-newest, drop-oldest or sample, see OverflowPolicy; it
+	// defaults to block, and to drop-newest for the file sink.
+	AsyncQueueSize int
+	AsyncPolicy    string
+}
+
+// fileQueueSize is the default number of lines queued for a slow log file.
+const fileQueueSize = 1024
+
+// Logging holds the writers created by SetupLogging
+type Logging struct {
+	Filter *LevelFilter
+	Gate   *GatedWriter
+	Writer *LogWriter
+	Output io.Writer
+	File   *RotatingFile // nil unless Config.LogFile is set
+
+	async   []*AsyncWriter
+	closers []func() error
+}
+
+// Flush waits until the asynchronous sinks have written what they have
+// queued
+func (l *Logging) Flush() {
+	for _, a := range l.async {
+		a.Flush()
+	}
+}
+
+// Dropped returns the number of messages the asynchronous sinks dropped
+// because their queue was full
+func (l *Logging) Dropped() uint64 {
+	var n uint64
+	for _, a := range l.async {
+		n += a.Dropped()
+	}
+	return n
+}
+
+// Close stops the file and syslog sinks, writing out what they have queued
+func (l *Logging) Close() error {
+	var err error
+	for i := len(l.closers) - 1; i >= 0; i-- {
+		if cerr := l.closers[i](); err == nil {
+			err = cerr
+		}
+	}
+	l.closers = nil
+	return err
 }
 
 // Setup is used to perform setup of serveral logging objects
 func Setup(config *Config) (*LevelFilter, *GatedWriter, *LogWriter, io.Writer, bool) {
+	l, err := SetupLogging(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", err.Error())
+		return nil, nil, nil, nil, false
+	}
+	return l.Filter, l.Gate, l.Writer, l.Output, true
+}
+
+// SetupLogging is like Setup, but returns an error and a Logging that can
+// be closed on shutdown
+func SetupLogging(config *Config) (*Logging, error) {
 	logGate := &GatedWriter{
 		Writer: os.Stdout,
 	}
@@ -25,26 +115,79 @@ func Setup(config *Config) (*LevelFilter, *GatedWriter, *LogWriter, io.Writer, b
 	logFilter.MinLevel = LogLevel(strings.ToUpper(config.LogLevel))
 	logFilter.Writer = logGate
 	if !ValidateLevelFilter(logFilter.MinLevel, logFilter) {
-		fmt.Fprintf(os.Stderr, "Invalid log level: %s. Valid log levels are: %v", logFilter.MinLevel, logFilter.Levels)
-		return nil, nil, nil, nil, false
+		return nil, fmt.Errorf("Invalid log level: %s. Valid log levels are: %v", logFilter.MinLevel, logFilter.Levels)
+	}
+
+	encoder, err := NewEncoder(config.LogFormat)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid log format: %s. Valid log formats are: text, json, logfmt", config.LogFormat)
+	}
+	logFilter.Encoder = encoder
+
+	policy, filePolicy := OverflowBlock, OverflowDropNewest
+	if config.AsyncPolicy != "" {
+		if policy, err = ParseOverflowPolicy(config.AsyncPolicy); err != nil {
+			return nil, fmt.Errorf("Invalid async policy: %s. Valid policies are: block, drop-newest, drop-oldest, sample", config.AsyncPolicy)
+		}
+		filePolicy = policy
+	}
+	fileQueue := fileQueueSize
+	if config.AsyncQueueSize > 0 {
+		fileQueue = config.AsyncQueueSize
+	}
+
+	l := &Logging{Filter: logFilter, Gate: logGate}
+	if config.LogFile != "" {
+		file := &RotatingFile{
+			Filename:   config.LogFile,
+			MaxSize:    int64(config.MaxSizeMB) << 20,
+			Daily:      true,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     time.Duration(config.MaxAgeDays) * 24 * time.Hour,
+			Compress:   config.Compress,
+		}
+		if err := file.Reopen(); err != nil {
+			return nil, fmt.Errorf("Log file setup error: %v", err)
+		}
+		queue := l.newAsync(file, fileQueue, filePolicy)
+		stop := reopenOnSignal(file)
+		l.File = file
+		l.closers = append(l.closers, func() error {
+			stop()
+			queue.Close()
+			return file.Close()
+		})
+		logFilter.Writer = io.MultiWriter(logGate, queue)
 	}
 
 	var syslog io.Writer
 	if config.EnableSyslog {
+		syslogConfig, err := newSyslogConfig(config)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+
 		retries := 12
 		delay := 5 * time.Second
 		for i := 0; i <= retries; i++ {
-			l, err := NewLogger(LOG_NOTICE, config.SyslogFacility, "FEINIUBUS")
+			s, err := DialSyslog(syslogConfig)
 			if err == nil {
-				syslog = &SyslogWrapper{l, logFilter}
+				l.closers = append(l.closers, s.Close)
+				syslog = &SyslogWrapper{s, logFilter}
+				if config.AsyncQueueSize > 0 {
+					a := l.newAsync(syslog, config.AsyncQueueSize, policy)
+					l.closers = append(l.closers, a.Close)
+					syslog = a
+				}
 				break
 			}
 
 			fmt.Fprintf(os.Stderr, "Syslog setup error: %v", err)
 			if i == retries {
+				l.Close()
 				timeout := time.Duration(retries) * delay
-				fmt.Fprintf(os.Stderr, "Syslog setup did not succeed within timeout (%s)", timeout.String())
-				return nil, nil, nil, nil, false
+				return nil, fmt.Errorf("Syslog setup did not succeed within timeout (%s)", timeout.String())
 			}
 
 			fmt.Fprintf(os.Stderr, "Retrying syslog setup in %s...", delay.String())
@@ -52,12 +195,52 @@ func Setup(config *Config) (*LevelFilter, *GatedWriter, *LogWriter, io.Writer, b
 		}
 	}
 
-	logWriter := NewLogWriter(512)
-	var logOutput io.Writer
+	l.Writer = NewLogWriter(512)
+	var logWriter io.Writer = l.Writer
+	if config.AsyncQueueSize > 0 {
+		a := l.newAsync(l.Writer, config.AsyncQueueSize, policy)
+		l.closers = append(l.closers, a.Close)
+		logWriter = a
+	}
+
 	if syslog != nil {
-		logOutput = io.MultiWriter(logFilter, logWriter, syslog)
+		l.Output = NewMultiWriter(logFilter, logWriter, syslog)
 	} else {
-		logOutput = io.MultiWriter(logFilter, logWriter)
+		l.Output = NewMultiWriter(logFilter, logWriter)
 	}
-	return logFilter, logGate, logWriter, logOutput, true
+	return l, nil
+}
+
+func newSyslogConfig(config *Config) (*SyslogConfig, error) {
+	format, err := ParseSyslogFormat(config.SyslogFormat)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid syslog format: %s. Valid syslog formats are: rfc3164, rfc5424", config.SyslogFormat)
+	}
+
+	syslogConfig := &SyslogConfig{
+		Network:  config.SyslogNetwork,
+		Address:  config.SyslogAddress,
+		Priority: LOG_NOTICE,
+		Facility: config.SyslogFacility,
+		Tag:      "FEINIUBUS",
+		Format:   format,
+
+		OctetCounting: config.SyslogOctetCounting,
+	}
+	if config.SyslogTLS {
+		if syslogConfig.Network == "" {
+			syslogConfig.Network = "tcp"
+		}
+		syslogConfig.TLSConfig, err = NewSyslogTLSConfig(config.SyslogTLSCAFile, config.SyslogTLSCertFile, config.SyslogTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Syslog TLS setup error: %v", err)
+		}
+	}
+	return syslogConfig, nil
+}
+
+func (l *Logging) newAsync(w io.Writer, size int, policy OverflowPolicy) *AsyncWriter {
+	a := NewAsyncWriter(w, size, policy)
+	l.async = append(l.async, a)
+	return a
 }
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingHandler struct {
+	logs []string
+}
+
+func (h *recordingHandler) HandleLog(s string) { h.logs = append(h.logs, s) }
+
+func fixedTime() func() {
+	now = func() time.Time { return time.Date(2018, 3, 1, 8, 30, 0, 0, time.UTC) }
+	return func() { now = time.Now }
+}
+
+func TestLoggerText(t *testing.T) {
+	defer fixedTime()()
+
+	var buf bytes.Buffer
+	log := New(&buf).With("service", "orders")
+	log.Info("order created", "id", 42, "note", "two words", "err", errors.New("x=1"))
+	log.WithCaller(false).Warn("odd", "key")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	first := regexp.MustCompile(`^2018/03/01 08:30:00 \[INFO\] logger_test\.go:\d+: order created service=orders id=42 note="two words" err="x=1"$`)
+	if !first.MatchString(lines[0]) {
+		t.Errorf("unexpected line %q", lines[0])
+	}
+	if want := "2018/03/01 08:30:00 [WARN] odd service=orders key=(MISSING)"; lines[1] != want {
+		t.Errorf("got %q, want %q", lines[1], want)
+	}
+}
+
+func TestLoggerSetupPipeline(t *testing.T) {
+	filter, gate, writer, output, ok := Setup(&Config{LogLevel: "warn"})
+	if !ok {
+		t.Fatal("setup failed")
+	}
+	var stdout bytes.Buffer
+	gate.Writer = &stdout
+	gate.Flush()
+
+	handler := &recordingHandler{}
+	writer.RegisterHandler(handler)
+
+	log := New(output)
+	log.Debug("hidden")
+	log.Error("shown", "code", 500)
+
+	if out := stdout.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "[ERR]") {
+		t.Errorf("filter output = %q", out)
+	}
+	if len(handler.logs) != 2 {
+		t.Errorf("log writer received %d records", len(handler.logs))
+	}
+	if filter.Check([]byte("[DEBUG] x")) {
+		t.Error("filter let DEBUG through")
+	}
+}
+
+type fakeSyslog struct {
+	priorities []Priority
+	messages   []string
+}
+
+func (s *fakeSyslog) WriteLevel(p Priority, b []byte) error {
+	s.priorities = append(s.priorities, p)
+	s.messages = append(s.messages, string(b))
+	return nil
+}
+
+func (s *fakeSyslog) Write(b []byte) (int, error) { return len(b), nil }
+
+func (s *fakeSyslog) Close() error { return nil }
+
+func TestEncoders(t *testing.T) {
+	r := &Record{
+		Time:    time.Date(2018, 3, 1, 8, 30, 0, 0, time.UTC),
+		Level:   LevelWarn,
+		Caller:  "main.go:7",
+		Message: `say "hi"`,
+		Fields:  []Field{{"n", 1}, {"err", errors.New("boom")}, {"tags", []string{"a"}}},
+	}
+
+	tests := map[string]string{
+		"text":   `2018/03/01 08:30:00 [WARN] main.go:7: say "hi" n=1 err=boom tags=[a]` + "\n",
+		"json":   `{"ts":"2018-03-01T08:30:00Z","level":"WARN","caller":"main.go:7","msg":"say \"hi\"","n":1,"err":"boom","tags":["a"]}` + "\n",
+		"logfmt": `ts=2018-03-01T08:30:00Z level=WARN caller=main.go:7 msg="say \"hi\"" n=1 err=boom tags=[a]` + "\n",
+	}
+	for format, want := range tests {
+		enc, err := NewEncoder(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(enc.Encode(nil, r)); got != want {
+			t.Errorf("%s:\n got %s\nwant %s", format, got, want)
+		}
+	}
+	if _, err := NewEncoder("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestRecordLevels(t *testing.T) {
+	filter, gate, writer, _, ok := Setup(&Config{LogLevel: "warn", LogFormat: "json"})
+	if !ok {
+		t.Fatal("setup failed")
+	}
+	var stdout bytes.Buffer
+	gate.Writer = &stdout
+	gate.Flush()
+
+	syslog := &fakeSyslog{}
+	log := New(NewMultiWriter(filter, writer, &SyslogWrapper{syslog, filter})).WithCaller(false)
+	log.Info("[ERR] not an error")
+	log.Warn("[DEBUG] not debug", "k", "v")
+
+	out := stdout.String()
+	if strings.Contains(out, "not an error") || !strings.Contains(out, `"level":"WARN","msg":"[DEBUG] not debug","k":"v"`) {
+		t.Errorf("stdout = %q", out)
+	}
+	if len(syslog.messages) != 1 || syslog.priorities[0] != LOG_WARNING || syslog.messages[0] != "[DEBUG] not debug k=v" {
+		t.Errorf("syslog = %v %q", syslog.priorities, syslog.messages)
+	}
+}
+
+func TestSyslogConfigOctetCounting(t *testing.T) {
+	c, err := newSyslogConfig(&Config{SyslogNetwork: "tcp", SyslogAddress: "h:514", SyslogOctetCounting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.OctetCounting {
+		t.Error("SyslogOctetCounting was not passed on")
+	}
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"io"
+)
+
+// multiWriter is an io.MultiWriter that also passes records on, so that
+// each writer can filter and encode them itself.
+type multiWriter struct {
+	writers []io.Writer
+}
+
+// NewMultiWriter creates a writer that duplicates its writes and records to
+// all the provided writers. Writers that are not a RecordWriter receive
+// records encoded with TextEncoder.
+func NewMultiWriter(writers ...io.Writer) io.Writer {
+	w := make([]io.Writer, len(writers))
+	copy(w, writers)
+	return &multiWriter{w}
+}
+
+// Write is used to implement io.Writer
+func (t *multiWriter) Write(p []byte) (n int, err error) {
+	for _, w := range t.writers {
+		n, err = w.Write(p)
+		if err != nil {
+			return
+		}
+		if n != len(p) {
+			err = io.ErrShortWrite
+			return
+		}
+	}
+	return len(p), nil
+}
+
+// WriteRecord is used to implement RecordWriter
+func (t *multiWriter) WriteRecord(r *Record) error {
+	for _, w := range t.writers {
+		if err := writeRecord(w, nil, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Log levels used by Logger. They match the levels of NewLevelFilter.
+const (
+	LevelTrace LogLevel = "TRACE"
+	LevelDebug LogLevel = "DEBUG"
+	LevelInfo  LogLevel = "INFO"
+	LevelWarn  LogLevel = "WARN"
+	LevelError LogLevel = "ERR"
+)
+
+// Field is a key/value pair attached to a log record
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Record is a single structured log message
+type Record struct {
+	Time    time.Time
+	Level   LogLevel
+	Caller  string
+	Message string
+	Fields  []Field
+}
+
+// missingValue is the value of a key passed without one.
+const missingValue = "(MISSING)"
+
+// fields converts alternating keys and values to fields. Keys that are not
+// strings are formatted with fmt.Sprint.
+func fields(keyvals []interface{}) []Field {
+	fs := make([]Field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		var value interface{} = missingValue
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		fs = append(fs, Field{Key: key, Value: value})
+	}
+	return fs
+}
+
+// RecordWriter is implemented by writers that accept structured records
+// as well as encoded lines. WriteRecord must not retain r.
+type RecordWriter interface {
+	WriteRecord(r *Record) error
+}
+
+// writeRecord passes r to w if it is a RecordWriter, and writes it encoded
+// with enc otherwise. A nil enc is TextEncoder.
+func writeRecord(w io.Writer, enc Encoder, r *Record) error {
+	if rw, ok := w.(RecordWriter); ok {
+		return rw.WriteRecord(r)
+	}
+	if enc == nil {
+		enc = TextEncoder{}
+	}
+
+	bp := bufPool.Get().(*[]byte)
+	buf := enc.Encode((*bp)[:0], r)
+	_, err := w.Write(buf)
+	*bp = buf
+	bufPool.Put(bp)
+	return err
+}
+
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// appendText formats r as a line the LevelFilter understands:
+// 2006/01/02 15:04:05 [LEVEL] file.go:12: message key=value
+func (r *Record) appendText(buf []byte) []byte {
+	buf = r.Time.AppendFormat(buf, "2006/01/02 15:04:05")
+	buf = append(buf, " ["...)
+	buf = append(buf, r.Level...)
+	buf = append(buf, "] "...)
+	return append(r.appendMessage(buf), '\n')
+}
+
+// appendMessage formats r without time and level:
+// file.go:12: message key=value
+func (r *Record) appendMessage(buf []byte) []byte {
+	if r.Caller != "" {
+		buf = append(buf, r.Caller...)
+		buf = append(buf, ": "...)
+	}
+	buf = append(buf, r.Message...)
+	for _, f := range r.Fields {
+		buf = append(buf, ' ')
+		buf = appendTextValue(buf, f.Key)
+		buf = append(buf, '=')
+		buf = appendTextValue(buf, formatValue(f.Value))
+	}
+	return buf
+}
+
+// formatValue returns the text form of a field value.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// appendTextValue appends s, quoted if it is empty or contains spaces,
+// quotes, '=' or control characters.
+func appendTextValue(buf []byte, s string) []byte {
+	if s == "" || strings.IndexFunc(s, needsQuote) >= 0 {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+func needsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError
+}
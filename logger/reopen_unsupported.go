@@ -0,0 +1,8 @@
+// +build windows plan9
+
+package logger
+
+// reopenOnSignal does nothing on platforms without SIGHUP.
+func reopenOnSignal(f *RotatingFile) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,283 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is an io.WriteCloser that writes to Filename and moves it
+// aside as a backup, named like app-2006-01-02T15-04-05.000.log, when it
+// would grow beyond MaxSize bytes or, if Daily is set, when the day changes.
+// The file is opened on the first write.
+type RotatingFile struct {
+	Filename   string
+	MaxSize    int64         // 0 disables rotation by size
+	Daily      bool          // rotate when the local date changes
+	MaxBackups int           // 0 keeps all backups
+	MaxAge     time.Duration // 0 keeps backups regardless of age
+	Compress   bool          // gzip backups
+
+	mu     sync.Mutex // guards file, size, day and millCh
+	file   *os.File
+	size   int64
+	day    string
+	millCh chan struct{}
+	millWg sync.WaitGroup
+}
+
+// Write is used to implement io.Writer
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := now()
+	if f.file == nil {
+		if err := f.open(t); err != nil {
+			return 0, err
+		}
+	}
+	if (f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize) ||
+		(f.Daily && t.Format("2006-01-02") != f.day) {
+		if err := f.rotate(t); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file aside and starts a new one
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rotate(now())
+}
+
+// Reopen closes and reopens the file, for use after an external tool such
+// as logrotate has moved it
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.close(); err != nil {
+		return err
+	}
+	return f.open(now())
+}
+
+// Close closes the file and waits for pending compression and cleanup
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	err := f.close()
+	if f.millCh != nil {
+		close(f.millCh)
+		f.millCh = nil
+	}
+	f.mu.Unlock()
+
+	f.millWg.Wait()
+	return err
+}
+
+// open opens or creates the file. It must be called with f.mu held.
+func (f *RotatingFile) open(t time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(f.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.day = t.Format("2006-01-02")
+	if info.Size() > 0 {
+		f.day = info.ModTime().Format("2006-01-02")
+	}
+	return nil
+}
+
+// close must be called with f.mu held.
+func (f *RotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rotate must be called with f.mu held.
+func (f *RotatingFile) rotate(t time.Time) error {
+	if err := f.close(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(f.Filename); err == nil {
+		if err := os.Rename(f.Filename, f.freeBackupName(t)); err != nil {
+			return err
+		}
+	}
+	if err := f.open(t); err != nil {
+		return err
+	}
+	f.day = t.Format("2006-01-02")
+	f.mill()
+	return nil
+}
+
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.Filename)
+	return strings.TrimSuffix(f.Filename, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// freeBackupName returns the backup name for t, moved on by a millisecond
+// at a time while a backup of that name exists, so that rotating twice
+// within a millisecond does not overwrite the first backup.
+func (f *RotatingFile) freeBackupName(t time.Time) string {
+	for {
+		name := f.backupName(t)
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return !os.IsNotExist(err)
+}
+
+// mill asks the background goroutine to compress and remove backups, so
+// that rotation does not wait for it. It must be called with f.mu held.
+func (f *RotatingFile) mill() {
+	if !f.Compress && f.MaxBackups <= 0 && f.MaxAge <= 0 {
+		return
+	}
+	if f.millCh == nil {
+		f.millCh = make(chan struct{}, 1)
+		f.millWg.Add(1)
+		go f.runMill(f.millCh)
+	}
+	select {
+	case f.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (f *RotatingFile) runMill(ch chan struct{}) {
+	defer f.millWg.Done()
+	for range ch {
+		f.cleanup()
+	}
+}
+
+type backupFile struct {
+	path string
+	time time.Time
+}
+
+// cleanup removes and compresses backups according to MaxBackups, MaxAge
+// and Compress.
+func (f *RotatingFile) cleanup() {
+	backups := f.backups()
+
+	var remove []backupFile
+	if f.MaxBackups > 0 && len(backups) > f.MaxBackups {
+		remove = append(remove, backups[f.MaxBackups:]...)
+		backups = backups[:f.MaxBackups]
+	}
+	if f.MaxAge > 0 {
+		cutoff := now().Add(-f.MaxAge)
+		for i := len(backups) - 1; i >= 0 && backups[i].time.Before(cutoff); i-- {
+			remove = append(remove, backups[i])
+			backups = backups[:i]
+		}
+	}
+	for _, b := range remove {
+		os.Remove(b.path)
+	}
+
+	if f.Compress {
+		for _, b := range backups {
+			if !strings.HasSuffix(b.path, ".gz") {
+				compressFile(b.path)
+			}
+		}
+	}
+}
+
+// backups returns the backups of the file, newest first.
+func (f *RotatingFile) backups() []backupFile {
+	dir := filepath.Dir(f.Filename)
+	ext := filepath.Ext(f.Filename)
+	prefix := strings.TrimSuffix(filepath.Base(f.Filename), ext) + "-"
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var backups []backupFile
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{filepath.Join(dir, name), t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups
+}
+
+// compressFile replaces name with name.gz.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		src.Close()
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	src.Close()
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readDir(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := time.Date(2018, 3, 1, 8, 30, 0, 0, time.Local)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	f := &RotatingFile{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		clock = clock.Add(time.Second)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"app-2018-03-01T08-30-02.000.log.gz",
+		"app-2018-03-01T08-30-03.000.log.gz",
+		"app.log",
+	}
+	if names := readDir(t, dir); strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+
+	gz, err := os.Open(filepath.Join(dir, want[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	r, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(r); string(data) != "third\n" {
+		t.Errorf("backup = %q", data)
+	}
+}
+
+func TestRotatingFileSameMillisecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := time.Date(2018, 3, 1, 8, 30, 0, 0, time.Local)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	f := &RotatingFile{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxSize:  5,
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"app-2018-03-01T08-30-00.000.log",
+		"app-2018-03-01T08-30-00.001.log",
+		"app.log",
+	}
+	if names := readDir(t, dir); strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	for i, line := range []string{"first\n", "second\n", "third\n"} {
+		if data, _ := ioutil.ReadFile(filepath.Join(dir, want[i])); string(data) != line {
+			t.Errorf("%s = %q, want %q", want[i], data, line)
+		}
+	}
+}
+
+func TestRotatingFileDailyAndReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := time.Date(2018, 3, 1, 23, 59, 0, 0, time.Local)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	name := filepath.Join(dir, "app.log")
+	f := &RotatingFile{Filename: name, Daily: true}
+	defer f.Close()
+
+	f.Write([]byte("day one\n"))
+	clock = clock.Add(2 * time.Minute)
+	f.Write([]byte("day two\n"))
+	if names := readDir(t, dir); len(names) != 2 || names[0] != "app-2018-03-02T00-01-00.000.log" {
+		t.Fatalf("files = %v", names)
+	}
+
+	// an external logrotate moves the file away
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("after reopen\n"))
+	if data, _ := ioutil.ReadFile(name); string(data) != "after reopen\n" {
+		t.Errorf("app.log = %q", data)
+	}
+}
+
+func TestSetupLoggingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "logs", "app.log")
+	l, err := SetupLogging(&Config{LogLevel: "info", LogFormat: "logfmt", LogFile: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := New(l.Output).WithCaller(false)
+	log.Debug("hidden")
+	log.Info("started", "port", 8080)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Contains(s, "hidden") || !strings.HasSuffix(s, "level=INFO msg=started port=8080\n") {
+		t.Errorf("log file = %q", s)
+	}
+}
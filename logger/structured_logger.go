@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"io"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// Logger writes leveled, structured log records to an io.Writer, such as
+// the output returned by Setup. Records are passed as they are to a
+// RecordWriter; other writers receive each record encoded as one line.
+type Logger struct {
+	out     io.Writer
+	encoder Encoder
+	mu      *sync.Mutex // shared with children, serializes writes to out
+	fields  []Field
+	caller  bool
+}
+
+// New returns a Logger that writes to out and records the caller
+func New(out io.Writer) *Logger {
+	return &Logger{
+		out:    out,
+		mu:     new(sync.Mutex),
+		caller: true,
+	}
+}
+
+// With returns a child logger that adds the given key/value pairs to every
+// record
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	child := *l
+	child.fields = make([]Field, 0, len(l.fields)+(len(keyvals)+1)/2)
+	child.fields = append(child.fields, l.fields...)
+	child.fields = append(child.fields, fields(keyvals)...)
+	return &child
+}
+
+// WithCaller returns a child logger that does or does not record the caller
+func (l *Logger) WithCaller(enabled bool) *Logger {
+	child := *l
+	child.caller = enabled
+	return &child
+}
+
+// WithEncoder returns a child logger that encodes records with enc when
+// its output is not a RecordWriter
+func (l *Logger) WithEncoder(enc Encoder) *Logger {
+	child := *l
+	child.encoder = enc
+	return &child
+}
+
+// Trace logs a message at TRACE level
+func (l *Logger) Trace(msg string, keyvals ...interface{}) {
+	l.log(LevelTrace, msg, keyvals)
+}
+
+// Debug logs a message at DEBUG level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+// Info logs a message at INFO level
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+// Warn logs a message at WARN level
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LevelWarn, msg, keyvals)
+}
+
+// Error logs a message at ERR level
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+// Log logs a message at the given level
+func (l *Logger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	l.log(level, msg, keyvals)
+}
+
+// log must be called directly by the exported methods, so that the caller
+// is found at a fixed depth.
+func (l *Logger) log(level LogLevel, msg string, keyvals []interface{}) {
+	r := Record{
+		Time:    now(),
+		Level:   level,
+		Message: msg,
+		Fields:  l.fields,
+	}
+	if len(keyvals) > 0 {
+		r.Fields = append(r.Fields[:len(r.Fields):len(r.Fields)], fields(keyvals)...)
+	}
+	if l.caller {
+		if _, file, line, ok := runtime.Caller(2); ok {
+			r.Caller = filepath.Base(file) + ":" + strconv.Itoa(line)
+		}
+	}
+
+	l.mu.Lock()
+	writeRecord(l.out, l.encoder, &r)
+	l.mu.Unlock()
+}
@@ -67,3 +67,22 @@ func (s *SyslogWrapper) Write(p []byte) (int, error) {
 	err := s.l.WriteLevel(priority, afterLevel)
 	return len(p), err
 }
+
+// WriteRecord is used to implement RecordWriter. The level of the record
+// selects the priority and the message is written without time and level.
+// A RecordSyslogger receives the record itself.
+func (s *SyslogWrapper) WriteRecord(r *Record) error {
+	if !s.filter.Enabled(r.Level) {
+		return nil
+	}
+
+	priority, ok := levelPriority[string(r.Level)]
+	if !ok {
+		priority = LOG_NOTICE
+	}
+
+	if rs, ok := s.l.(RecordSyslogger); ok {
+		return rs.WriteRecordLevel(priority, r)
+	}
+	return s.l.WriteLevel(priority, r.appendMessage(nil))
+}
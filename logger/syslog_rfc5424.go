@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// SyslogFormat selects the format of syslog messages
+type SyslogFormat int
+
+// Syslog formats
+const (
+	// SyslogRFC3164 is the BSD format <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
+	SyslogRFC3164 SyslogFormat = iota
+	// SyslogRFC5424 is the format
+	// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	SyslogRFC5424
+)
+
+// ParseSyslogFormat parses rfc3164 (or an empty string) and rfc5424
+func ParseSyslogFormat(s string) (SyslogFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "rfc3164":
+		return SyslogRFC3164, nil
+	case "rfc5424":
+		return SyslogRFC5424, nil
+	}
+	return 0, fmt.Errorf("invalid syslog format: %s", s)
+}
+
+// DefaultSDID is the SD-ID of the STRUCTURED-DATA element that holds the
+// fields of a record. 32473 is the private enterprise number reserved for
+// documentation; set SyslogConfig.SDID to use your own.
+const DefaultSDID = "fields@32473"
+
+// MsgIDKey is the field whose value becomes the RFC 5424 MSGID instead of a
+// STRUCTURED-DATA parameter
+const MsgIDKey = "msgid"
+
+// SyslogConfig is used to connect to a syslog server with DialSyslog
+type SyslogConfig struct {
+	Network  string // empty for the local syslog server, or udp, tcp, ...
+	Address  string
+	Priority Priority
+	Facility string
+	Tag      string // TAG, or APP-NAME for RFC 5424; defaults to os.Args[0]
+
+	Format SyslogFormat
+	SDID   string // defaults to DefaultSDID
+
+	// OctetCounting frames stream messages as "LEN MSG" (RFC 6587)
+	// instead of terminating them with a newline. It is always used
+	// with TLS.
+	OctetCounting bool
+
+	// TLSConfig, if set, connects over TLS (RFC 5425). Network must then
+	// be tcp, tcp4 or tcp6. See NewSyslogTLSConfig.
+	TLSConfig *tls.Config
+}
+
+// RecordSyslogger is implemented by Sysloggers that write the fields of a
+// record as structured data
+type RecordSyslogger interface {
+	WriteRecordLevel(Priority, *Record) error
+}
+
+// NewSyslogTLSConfig returns a TLS config that trusts the CA certificates
+// in caFile, or the system roots if caFile is empty, and presents the
+// client certificate in certFile and keyFile if they are given
+func NewSyslogTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
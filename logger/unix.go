@@ -43,10 +43,45 @@ func DialLogger(network, raddr string, p Priority, facility, tag string) (Syslog
 	return &builtinLogger{l}, nil
 }
 
+// DialSyslog is used to construct a new Syslogger from a SyslogConfig
+func DialSyslog(config *SyslogConfig) (Syslogger, error) {
+	fPriority, err := facilityPriority(config.Facility)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := dialBuiltinWriter(&builtinWriter{
+		priority:      syslog.Priority(config.Priority) | fPriority,
+		tag:           config.Tag,
+		network:       config.Network,
+		raddr:         config.Address,
+		format:        config.Format,
+		octetCounting: config.OctetCounting,
+		tlsConfig:     config.TLSConfig,
+		sdID:          config.SDID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &builtinLogger{l}, nil
+}
+
+// WriteRecordLevel writes out a record at the given priority. With
+// SyslogRFC5424 its fields are written as STRUCTURED-DATA.
+func (b *builtinLogger) WriteRecordLevel(p Priority, r *Record) error {
+	priority, err := syslogPriority(p)
+	if err != nil {
+		return err
+	}
+	_, err = b.writeAndRetry(priority, recordMessage(b.format, b.sdID, r))
+	return err
+}
+
 // WriteLevel writes out a message at the given priority
 func (b *builtinLogger) WriteLevel(p Priority, buf []byte) error {
 	var err error
-	m := string(buf)
+	m := message{msg: string(buf)}
 	switch p {
 	case LOG_EMERG:
 		_, err = b.writeAndRetry(syslog.LOG_EMERG, m)
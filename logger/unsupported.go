@@ -9,6 +9,11 @@ func NewLogger(p Priority, facility, tag string) (Syslogger, error) {
 	return nil, fmt.Errorf("Platform does not support syslog")
 }
 
+// DialSyslog is used to construct a new Syslogger from a SyslogConfig
+func DialSyslog(config *SyslogConfig) (Syslogger, error) {
+	return nil, fmt.Errorf("Platform does not support syslog")
+}
+
 // DialLogger is used to construct a new Syslogger that establishes connection to remote syslog server
 func DialLogger(network, raddr string, p Priority, facility, tag string) (Syslogger, error) {
 	return nil, fmt.Errorf("Platform does not support syslog")